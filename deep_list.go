@@ -1,18 +1,84 @@
 package tview
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 
 	"github.com/gdamore/tcell/v2"
 )
 
+// typeAheadTimeout is the time after which the type-ahead search buffer is
+// reset if no further keys were typed.
+const typeAheadTimeout = time.Second
+
+// shortcutTimeout is the maximum time between the keys of a string shortcut.
+const shortcutTimeout = time.Second
+
+// deepListIndent is the number of cells sub items are indented per level.
+const deepListIndent = 2
+
+// deepListIconWidth is the width of the icon column of a DeepList, including
+// a gap before the item texts.
+const deepListIconWidth = 2
+
+// deepListSpinner contains the frames of the spinner drawn for items which are
+// loading.
+var deepListSpinner = []rune{'|', '/', '-', '\\'}
+
+// selectAnimationFrames is the number of times a selected item is drawn in the
+// select animation style when the select animation is enabled.
+const selectAnimationFrames = 2
+
+// ScrollbarVisibility specifies when a DeepList draws a vertical scrollbar.
+type ScrollbarVisibility int
+
+// Scrollbar visibilities.
+const (
+	ScrollbarNever  ScrollbarVisibility = iota // Never draw a scrollbar.
+	ScrollbarAuto                              // Draw a scrollbar only if the items don't fit.
+	ScrollbarAlways                            // Always draw a scrollbar.
+)
+
+// SelectedVisibility specifies how a DeepList scrolls to keep the selected item
+// in view.
+type SelectedVisibility int
+
+// Selected item visibilities.
+const (
+	SelectedVisibleEdge   SelectedVisibility = iota // Scroll only as far as needed to show the selected item.
+	SelectedVisibleCenter                           // Keep the selected item at the vertical center where possible.
+)
+
 type subList struct {
 	display bool
 	items   []*deepListItem
 }
 
+// deepListUndoItem is a state of a DeepList's item tree which can be restored
+// with Undo() or Redo().
+type deepListUndoItem struct {
+	items       []*deepListItem
+	currentItem []int
+}
+
+// DeepListItemSpec describes an item to be added to a DeepList, see
+// DeepList.AttachStream().
+type DeepListItemSpec struct {
+	MainText      string             // The main text of the list item.
+	SecondaryText string             // A secondary text to be shown underneath the main text.
+	Shortcut      rune               // The key to select the list item directly, 0 if there is no shortcut.
+	Selected      func()             // The optional function which is called when the item is selected.
+	Items         []DeepListItemSpec // The item's sub items.
+	Expanded      bool               // Whether the sub items are displayed.
+	Reference     interface{}        // An optional reference, see DeepList.SetItemReference().
+}
+
 // deepListItem represents one item in a DeepList.
 type deepListItem struct {
 	MainText      string // The main text of the list item.
@@ -21,6 +87,135 @@ type deepListItem struct {
 	Selected      func() // The optional function which is called when the item is selected.
 
 	SubList *subList // The sublist
+
+	// A shortcut consisting of a sequence of keys. It takes precedence over
+	// Shortcut when drawn. Empty if the item has no such shortcut.
+	stringShortcut string
+
+	// If secondaryVisibilitySet is true, secondaryVisible overrides the list's
+	// setting of whether the secondary text is shown.
+	secondaryVisibilitySet bool
+	secondaryVisible       bool
+
+	// Whether this item is a separator which is drawn as a horizontal line and
+	// which cannot be selected.
+	separator bool
+
+	// Whether this item is a section header which is drawn in the list's
+	// header style and which cannot be selected.
+	header bool
+
+	// A string drawn immediately before the main text. Empty if the item has
+	// no prefix.
+	prefix string
+
+	// A help text which is passed to the list's help handler. Empty if the
+	// item has no help text.
+	help string
+
+	// An optional reference to any user data, see DeepList.SetItemReference().
+	reference interface{}
+
+	// A text drawn at the right edge of the item's first row, e.g. a badge,
+	// and its style. Empty if the item has no trailing text.
+	trailingText  string
+	trailingStyle tcell.Style
+
+	// The number of cells skipped on the left side of the item's texts if
+	// horizontal scrolling per item is enabled.
+	horizontalOffset int
+
+	// Whether a spinner is drawn after the main text because the item's
+	// contents are loading.
+	loading bool
+
+	// The icon drawn in the icon column and its style. 0 if the item has no
+	// icon.
+	icon      rune
+	iconStyle tcell.Style
+
+	// MainText with expanded tabs and its screen width, cached, and whether
+	// they are up to date.
+	expandedMainText   string
+	mainTextWidth      int
+	mainTextWidthValid bool
+}
+
+// getMainTextWidth returns the screen width of the item's main text (without
+// color tags), with tabs expanded to the given tab size. The result is cached
+// until MainText is changed through setMainText() or the list's tab size
+// changes.
+func (i *deepListItem) getMainTextWidth(tabSize int) int {
+	i.cacheMainText(tabSize)
+	return i.mainTextWidth
+}
+
+// getExpandedMainText returns the item's main text with tabs expanded to the
+// given tab size. It is cached like the width, see getMainTextWidth().
+func (i *deepListItem) getExpandedMainText(tabSize int) string {
+	i.cacheMainText(tabSize)
+	return i.expandedMainText
+}
+
+// cacheMainText updates the cached expanded main text and its width if they
+// are outdated.
+func (i *deepListItem) cacheMainText(tabSize int) {
+	if !i.mainTextWidthValid {
+		i.expandedMainText = expandTabs(i.MainText, tabSize)
+		i.mainTextWidth = TaggedStringWidth(i.expandedMainText)
+		i.mainTextWidthValid = true
+	}
+}
+
+// expandTabs replaces the tab characters in the given text with spaces up to
+// the next multiple of the given tab size. Color tags don't count towards the
+// width.
+func expandTabs(text string, tabSize int) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	if tabSize < 1 {
+		tabSize = 1
+	}
+	var (
+		expanded strings.Builder
+		width    int
+	)
+	for index, field := range strings.Split(text, "\t") {
+		if index > 0 {
+			spaces := tabSize - width%tabSize
+			expanded.WriteString(strings.Repeat(" ", spaces))
+			width += spaces
+		}
+		expanded.WriteString(field)
+		width += TaggedStringWidth(field)
+	}
+	return expanded.String()
+}
+
+// setMainText sets the item's main text and invalidates cached values derived
+// from it.
+func (i *deepListItem) setMainText(text string) {
+	i.MainText = text
+	i.mainTextWidthValid = false
+}
+
+// deepListDepthStyle holds the text styles of items at a specific depth.
+type deepListDepthStyle struct {
+	main, secondary tcell.Style
+}
+
+// deepListRow is an item together with its path, as encountered when walking
+// the items of a DeepList in their visible order.
+type deepListRow struct {
+	path []int
+	item *deepListItem
+}
+
+// deepListDrawnRow is the screen position of a row drawn by DeepList.Draw().
+type deepListDrawnRow struct {
+	path      []int
+	y, height int
 }
 
 // DeepList displays rows of items, each of which can be selected. DeepList items can be
@@ -30,14 +225,42 @@ type deepListItem struct {
 //
 //   - Down arrow / tab: Move down one item.
 //   - Up arrow / backtab: Move up one item.
-//   - Home: Move to the first item.
-//   - End: Move to the last item.
+//   - Home: Move to the first visible item.
+//   - End: Move to the last visible item, which may be an item of a displayed
+//     sublist.
 //   - Page down: Move down one page.
 //   - Page up: Move up one page.
 //   - Enter / Space: Select the current item.
 //   - Right / left: Scroll horizontally. Only if the list is wider than the
 //     available space.
 //
+// If tree navigation is enabled (see [DeepList.SetTreeNavigation]), the right
+// and left arrow keys expand and collapse sublists instead.
+//
+// Key events can be intercepted with [Box.SetInputCapture] before the list
+// handles them, e.g. to map "j" and "k" to the down and up arrow keys:
+//
+//	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+//	  switch event.Rune() {
+//	  case 'j':
+//	    return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+//	  case 'k':
+//	    return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+//	  }
+//	  return event
+//	})
+//
+// The list's own methods, including all of its setters, may be called from any
+// goroutine, they are protected against data races by an internal mutex. This
+// does not apply to the methods of the embedded [Box], e.g. SetTitle(). It also
+// does not redraw the screen, nor does it make a sequence of calls atomic, so
+// changes from other goroutines should still preferably be made with
+// [Application.QueueUpdateDraw]. Callbacks, including the items'
+// "selected" functions, are invoked with the mutex unlocked and may call the
+// list's methods. Functions which compute something for the list, such as the
+// filter, the shortcut format, and the key functions, are called while the
+// mutex is locked and must not call the list's methods.
+//
 // See [DeepList.SetChangedFunc] for a way to be notified when the user navigates
 // to a list item. See [DeepList.SetSelectedFunc] for a way to be notified when a
 // list item was selected.
@@ -46,6 +269,10 @@ type deepListItem struct {
 type DeepList struct {
 	*Box
 
+	// Protects the items, the selection, and the state derived from them
+	// against concurrent access.
+	mutex *sync.Mutex
+
 	// The items of the list.
 	items []*deepListItem
 
@@ -64,6 +291,9 @@ type DeepList struct {
 	// The item shortcut text style.
 	shortcutStyle tcell.Style
 
+	// An optional function which returns the text drawn for a shortcut.
+	shortcutFormat func(shortcut rune) string
+
 	// The style for selected items.
 	selectedStyle tcell.Style
 
@@ -84,6 +314,14 @@ type DeepList struct {
 	// are not affected.
 	horizontalOffset int
 
+	// If true, each item has its own horizontal offset (see
+	// deepListItem.horizontalOffset) instead of horizontalOffset.
+	perItemScroll bool
+
+	// The number of cells by which the left and right arrow keys scroll the
+	// item texts horizontally.
+	scrollStep int
+
 	// Set to true if a currently visible item flows over the right border of
 	// the box. This is set by the Draw() function. It determines the behaviour
 	// of the right arrow key.
@@ -93,525 +331,4715 @@ type DeepList struct {
 	// list item.
 	changed func(indexes []int, mainText, secondaryText string, shortcut rune)
 
+	// An optional function which is called along with "changed", additionally
+	// receiving the previously reported path.
+	changedEx func(previous, current []int, mainText, secondaryText string, shortcut rune)
+
+	// The path reported by the last "changed" event (or the initial path).
+	reportedItem []int
+
 	// An optional function which is called when a list item was selected. This
 	// function will be called even if the list item defines its own callback.
 	selected func(index []int, mainText, secondaryText string, shortcut rune)
 
 	// An optional function which is called when the user presses the Escape key.
 	done func()
+
+	// An optional function which is called when the user presses the Escape
+	// key, receiving the selected item.
+	doneWithSelection func(index []int, mainText, secondaryText string, shortcut rune)
+
+	// An optional function which is called when the selection cannot be moved
+	// past the first or last item because wrapping is disabled.
+	boundary func(direction int)
+
+	// An optional function which is called when a sublist is displayed or
+	// hidden.
+	expanded func(path []int, expanded bool)
+
+	// An optional function which is called when the user right-clicks the
+	// list.
+	rightClick func(path []int, x, y int)
+
+	// An optional function which is called when an item is given a shortcut
+	// which another top-level item already has.
+	shortcutConflict func(shortcut rune, paths [][]int)
+
+	// An optional function which receives the text copied with the copy key,
+	// and the key itself. If copySecondaryText is true, the secondary text is
+	// copied along with the main text.
+	clipboard         func(text string)
+	copyKey           tcell.Key
+	copySecondaryText bool
+
+	// The key which moves the selection to the parent of the selected item.
+	parentKey tcell.Key
+
+	// An optional function which receives the help texts of items, and the
+	// key which requests the help text of the selected item.
+	help    func(path []int, help string)
+	helpKey rune
+
+	// If siblingKeys is true, the given keys (with the given modifiers) move
+	// the selection to the next or previous sibling.
+	siblingKeys                        bool
+	nextSiblingKey, previousSiblingKey tcell.Key
+	siblingModifiers                   tcell.ModMask
+
+	// An optional function which returns the key under which an item's
+	// expansion state is stored, see GetExpansionState(). It receives the main
+	// texts of the item's ancestors followed by the item's own main text.
+	expansionKey func(mainTexts []string) string
+
+	// An optional function which returns the key identifying an item, see
+	// GetSelectedKey().
+	itemKey func(path []int, mainText, secondaryText string, shortcut rune) string
+
+	// Whether or not typing letters which are not shortcuts jumps to matching
+	// items.
+	typeAhead bool
+
+	// The text typed so far for the type-ahead search and the time the last
+	// key was typed.
+	typeAheadBuffer string
+	typeAheadTime   time.Time
+
+	// The beginning of a string shortcut typed so far and the time the last
+	// key was typed.
+	shortcutBuffer string
+	shortcutTime   time.Time
+
+	// An optional function which determines which items are shown. Items for
+	// which it returns false are hidden unless one of their descendants is
+	// shown.
+	filter func(path []int, mainText, secondaryText string, shortcut rune) bool
+
+	// The text whose occurrences in item texts are highlighted, the style used
+	// for these occurrences, and whether the search ignores case.
+	searchHighlight       string
+	searchHighlightStyle  tcell.Style
+	searchHighlightNoCase bool
+
+	// The background color of every other item. Striping is disabled if this
+	// is tcell.ColorDefault.
+	alternateRowColor tcell.Color
+
+	// Whether or not main texts which don't fit the available width are
+	// wrapped onto multiple lines.
+	wrap bool
+
+	// The widths of the columns into which the tab-separated fields of main
+	// texts are laid out. If empty, main texts are drawn as they are.
+	columns []int
+
+	// The number of cells between tab stops in main texts.
+	tabSize int
+
+	// The alignment of item texts within the width remaining after the
+	// indentation, AlignLeft, AlignCenter, or AlignRight.
+	textAlign int
+
+	// If true, items are laid out from right to left.
+	rtl bool
+
+	// The text drawn in the center of the list when it has no items, and its
+	// style.
+	emptyText      string
+	emptyTextStyle tcell.Style
+
+	// Whether or not texts which don't fit the available width are cut off
+	// with an ellipsis instead of being scrolled horizontally.
+	ellipsis bool
+
+	// If true, the left and right arrow keys collapse and expand sublists and
+	// move between items and their sub items.
+	treeNavigation bool
+
+	// If true, the keys "j", "k", "h", "l", "g", and "G" navigate the list
+	// like in Vim instead of acting as shortcuts.
+	vimKeys bool
+
+	// If true, expanding an item moves the selection onto its first child and
+	// collapsing an item moves the selection from its descendants onto it.
+	selectChildOnExpand bool
+
+	// If true, hiding a sublist also hides the sublists of all descendants.
+	collapseResetsDescendants bool
+
+	// The key which displays (or, with the Alt modifier, hides) the sublists
+	// of the selected item and of all of its descendants.
+	expandRecursiveKey rune
+
+	// If titleFollowsSelection is true, the box title is set to the main texts
+	// of the selected item and its ancestors, joined with titleSeparator.
+	titleFollowsSelection bool
+	titleSeparator        string
+
+	// If true, displaying a sublist hides the sublists of the item's siblings.
+	accordion bool
+
+	// The number of levels of the item tree which are drawn at most, 0 for no
+	// limit. Deeper items are hidden as if their parents were collapsed.
+	maxVisibleDepth int
+
+	// If showChildCount is true, items with a hidden sublist show the number
+	// of their sub items (of all descendants if childCountTotal is true) in
+	// the given style.
+	showChildCount  bool
+	childCountTotal bool
+	childCountStyle tcell.Style
+
+	// The current frame of the spinner drawn for items which are loading.
+	spinnerFrame int
+
+	// Determines when a scrollbar is drawn on the right side of the list.
+	scrollbarVisibility ScrollbarVisibility
+
+	// Determines how the list scrolls to keep the selected item in view.
+	selectedVisibility SelectedVisibility
+
+	// Set to true while the user drags the scrollbar with the mouse.
+	scrollbarDragging bool
+
+	// If true, the item under the mouse cursor, hoverItem, is drawn in the
+	// hover style. hoverItem is nil if the mouse is not over an item. The list
+	// doesn't capture the mouse, so it doesn't notice when the mouse leaves it.
+	// Instead, hoverStale is set when the list is drawn and reset with every
+	// mouse event it receives. A stale hover item is not highlighted.
+	mouseHover bool
+	hoverItem  []int
+	hoverStale bool
+	hoverStyle tcell.Style
+
+	// If multiSelect is true, the items in multiSelection are drawn as
+	// selected instead of only the current item. Range selections extend from
+	// the anchor item. extendingSelection is set while the selection is
+	// changed such that the next "changed" event doesn't reduce it to the
+	// current item.
+	multiSelect        bool
+	multiSelection     map[*deepListItem]bool
+	anchorItem         *deepListItem
+	extendingSelection bool
+
+	// The multi-selection as last reported to the "selection changed"
+	// callback.
+	reportedSelection map[*deepListItem]bool
+	selectionChanged  func(paths [][]int)
+
+	// If selectAnimation is true, the item which was last selected, animatedItem,
+	// is drawn in the select animation style for the next animationFrames
+	// calls to Draw().
+	selectAnimation bool
+	animationStyle  tcell.Style
+	animatedItem    []int
+	animationFrames int
+
+	// The cached result of visibleRows() and whether it is up to date. It must
+	// be invalidated (see invalidateRows()) whenever the item tree, the
+	// display state of a sublist, or the filter changes.
+	rows      []deepListRow
+	rowsValid bool
+
+	// Layout information cached along with the visible rows: the number of
+	// screen rows above each visible row followed by the total, keyed by the
+	// width available to item texts (see lineStarts()), and the width of the
+	// shortcut column (see shortcutWidth()) and whether it is up to date. They
+	// must also be invalidated (see invalidateLayout()) whenever any of the
+	// texts or settings they depend on change.
+	lineStartsCache     map[int][]int
+	shortcutWidthCache  int
+	shortcutWidthCached bool
+
+	// The index of the selected item's row found last by currentRowIndex().
+	currentRow int
+
+	// The rows drawn by the last call to Draw() and the horizontal extent
+	// they were drawn in, see GetItemRect().
+	drawnRows          []deepListDrawnRow
+	drawnX, drawnWidth int
+
+	// The rune used to draw separators.
+	separatorRune rune
+
+	// The rune which fills the indentation of sub items and its style. The
+	// indentation is left blank if the rune is 0.
+	indentRune  rune
+	indentStyle tcell.Style
+
+	// Text styles overriding the list-wide styles for items at specific
+	// depths.
+	depthStyles map[int]deepListDepthStyle
+
+	// If dimAncestors is true, the ancestors of the selected item are drawn in
+	// the ancestor style.
+	dimAncestors  bool
+	ancestorStyle tcell.Style
+
+	// The style of section headers.
+	headerStyle tcell.Style
+
+	// The style of item prefixes.
+	prefixStyle tcell.Style
+
+	// If true, the header of the section containing the first drawn item
+	// remains visible at the top when it is scrolled out of view.
+	stickyHeaders bool
+
+	// Whether a column is reserved for item icons left of the item texts.
+	iconColumn bool
+
+	// The width of the shortcut column including the gap before the item
+	// texts, or 0 if it fits the widest shortcut.
+	shortcutColumnWidth int
+
+	// The number of empty cells left and right of each item's contents.
+	itemPaddingLeft, itemPaddingRight int
+
+	// Set to true while the function passed to BatchUpdate() runs.
+	batching bool
+
+	// Whether a "changed" event was suppressed during a batch update.
+	batchChanged bool
+
+	// Undo/redo related fields. The undo stack holds copies of the item tree
+	// taken before structural changes, at most historyLimit of them (no
+	// history is kept if the limit is 0). Undos and redos swap the list's state
+	// with the one at the current position and move nextUndo accordingly.
+	historyLimit int
+	undoStack    []deepListUndoItem
+	nextUndo     int
+
+	// The function through which streamed items are added, e.g.
+	// Application.QueueUpdateDraw. They are added directly if it is nil.
+	queueUpdate func(f func())
+
+	// If true, the list scrolls to the end when streamed items are added.
+	streamAutoScroll bool
+
+	// If follow is true, the list keeps its end in view when items are added,
+	// unless following is false because the user scrolled away from the end.
+	follow    bool
+	following bool
 }
 
 // NewDeepList returns a new list.
 func NewDeepList() *DeepList {
 	return &DeepList{
 		Box:                NewBox(),
+		mutex:              &sync.Mutex{},
 		showSecondaryText:  true,
 		wrapAround:         true,
 		currentItem:        []int{0},
+		reportedItem:       []int{0},
 		mainTextStyle:      tcell.StyleDefault.Foreground(Styles.PrimaryTextColor),
 		secondaryTextStyle: tcell.StyleDefault.Foreground(Styles.TertiaryTextColor),
+		separatorRune:      BoxDrawingsLightHorizontal,
+		tabSize:            TabSize,
+		scrollStep:         2, // We shift by 2 to account for two-cell characters.
+		headerStyle:        tcell.StyleDefault.Foreground(Styles.TitleColor).Attributes(tcell.AttrBold),
 		shortcutStyle:      tcell.StyleDefault.Foreground(Styles.SecondaryTextColor),
+		prefixStyle:        tcell.StyleDefault.Foreground(Styles.SecondaryTextColor),
+		childCountStyle:    tcell.StyleDefault.Foreground(Styles.TertiaryTextColor).Attributes(tcell.AttrDim),
+		ancestorStyle:      tcell.StyleDefault.Foreground(Styles.TertiaryTextColor).Attributes(tcell.AttrDim),
+		indentStyle:        tcell.StyleDefault.Foreground(Styles.GraphicsColor),
+		hoverStyle:         tcell.StyleDefault.Background(Styles.ContrastBackgroundColor),
+		animationStyle:     tcell.StyleDefault.Background(Styles.MoreContrastBackgroundColor),
+		copyKey:            tcell.KeyCtrlY,
+		parentKey:          tcell.KeyBackspace2,
+		helpKey:            '?',
+		expandRecursiveKey: '*',
 		selectedStyle:      tcell.StyleDefault.Foreground(Styles.PrimitiveBackgroundColor).Background(Styles.PrimaryTextColor),
 	}
 }
 
-func parseIndexes(index int, indexes []int, items []*deepListItem) []int {
-	if indexes[index] < 0 {
-		indexes[index] = len(items) + indexes[index]
-	}
-	if indexes[index] >= len(items) {
-		index = len(items) - 1
-	}
-	if index < 0 {
-		index = 0
+// parseIndexes resolves the given path against the given items. At each level,
+// negative indices refer to items from the back (-1 = last item) and out of
+// range indices are clamped to the first/last item. The path is truncated at
+// levels without items. An empty path, or any path into an empty list, results
+// in []int{0}. The returned flag is true if no index had to be clamped and the
+// path was not truncated. The given path is not modified.
+func parseIndexes(indexes []int, items []*deepListItem) ([]int, bool) {
+	if len(indexes) == 0 || len(items) == 0 {
+		return []int{0}, false
 	}
 
-	if index+1 >= len(indexes) {
-		return indexes
-	}
-
-	item := items[index]
+	ok := true
+	path := make([]int, 0, len(indexes))
+	for _, index := range indexes {
+		if len(items) == 0 {
+			ok = false
+			break
+		}
+		if index < 0 {
+			index += len(items)
+		}
+		if index < 0 {
+			index, ok = 0, false
+		} else if index >= len(items) {
+			index, ok = len(items)-1, false
+		}
+		path = append(path, index)
 
-	if item.SubList == nil || len(item.SubList.items) == 0 {
-		return indexes
+		item := items[index]
+		items = nil
+		if item.SubList != nil {
+			items = item.SubList.items
+		}
 	}
-
-	return parseIndexes(index+1, indexes, item.SubList.items)
+	return path, ok
 }
 
-func getItem(index int, indexes []int, items []*deepListItem) (*deepListItem, []*deepListItem) {
-	item := items[index]
-
-	if index+1 >= len(indexes) {
-		return item, items
+// normalizeCurrentItem makes sure that the current item refers to an existing
+// item by clamping its indices and truncating it where needed, see
+// parseIndexes(). It must be called after structural changes to the item tree
+// and returns whether the current item changed. No "changed" event is fired.
+func (l *DeepList) normalizeCurrentItem() bool {
+	path, ok := parseIndexes(l.currentItem, l.items)
+	if !ok {
+		l.currentItem = path
+		if item := l.itemAt(path); item != nil && (item.separator || item.header) {
+			l.currentItem = l.closestSelectable(path)
+		}
 	}
+	return !ok && len(l.items) > 0
+}
 
-	if item.SubList == nil || len(item.SubList.items) == 0 {
-		return item, items
+// closestSelectable returns the path of the item which can be selected and
+// which is closest to the given path: the item at the path itself or else its
+// next sibling which is not a separator or a section header or else such a
+// previous sibling or else its parent. The last index may equal the number of
+// siblings, e.g. for the position of a removed last item. The item's ancestors
+// must exist. If no top-level item can be selected, []int{0} is returned.
+func (l *DeepList) closestSelectable(path []int) []int {
+	depth := len(path) - 1
+	siblings := l.items
+	if depth > 0 {
+		if parent := l.itemAt(path[:depth]); parent != nil && parent.SubList != nil {
+			siblings = parent.SubList.items
+		} else {
+			siblings = nil
+		}
 	}
-
-	if item.SubList.display == false {
-		item.SubList.display = true
+	index := path[depth]
+	if index > len(siblings) {
+		index = len(siblings)
+	}
+	for sibling := index; sibling < len(siblings); sibling++ {
+		if !siblings[sibling].separator && !siblings[sibling].header {
+			return append(path[:depth:depth], sibling)
+		}
 	}
+	for sibling := index - 1; sibling >= 0; sibling-- {
+		if !siblings[sibling].separator && !siblings[sibling].header {
+			return append(path[:depth:depth], sibling)
+		}
+	}
+	if depth > 0 {
+		return append([]int(nil), path[:depth]...)
+	}
+	return []int{0}
+}
 
-	return getItem(index+1, indexes, item.SubList.items)
+// itemAt returns the item at the given path or nil if the path does not lead
+// to an existing item.
+func (l *DeepList) itemAt(path []int) *deepListItem {
+	if len(path) == 0 {
+		return nil
+	}
+	items := l.items
+	var item *deepListItem
+	for _, index := range path {
+		if index < 0 || index >= len(items) {
+			return nil
+		}
+		item = items[index]
+		items = nil
+		if item.SubList != nil {
+			items = item.SubList.items
+		}
+	}
+	return item
 }
 
-func removeItem(index int, indexes []int, items []*deepListItem) int {
-	item := items[index]
+// copyItems returns a deep copy of the given items, including their sublists.
+func copyItems(items []*deepListItem) []*deepListItem {
+	if items == nil {
+		return nil
+	}
+	copies := make([]*deepListItem, len(items))
+	for index, item := range items {
+		itemCopy := *item
+		if item.SubList != nil {
+			itemCopy.SubList = &subList{
+				display: item.SubList.display,
+				items:   copyItems(item.SubList.items),
+			}
+		}
+		copies[index] = &itemCopy
+	}
+	return copies
+}
 
-	if index+1 >= len(indexes) {
-		items = append(items[:indexes[index]], items[indexes[index]+1:]...)
+// removeItem removes the item with the given index from the given items and
+// returns the remaining items. The given slice is modified.
+func removeItem(items []*deepListItem, index int) []*deepListItem {
+	copy(items[index:], items[index+1:])
+	items[len(items)-1] = nil
+	return items[:len(items)-1]
+}
 
-		return len(items)
+// rowIndex returns the index of the row with the given path or -1 if no row
+// has this path.
+func rowIndex(rows []deepListRow, path []int) int {
+	for index, row := range rows {
+		if equals(row.path, path) {
+			return index
+		}
 	}
+	return -1
+}
 
-	if item.SubList == nil || len(item.SubList.items) == 0 {
-		return len(items)
+// shortcutWidth returns the width of the column reserved for shortcuts, left of
+// the item texts. Unless set with SetShortcutWidth(), it fits the widest
+// formatted shortcut of all top-level items, followed by a gap. It is 0 if no
+// top-level item has a shortcut.
+func (l *DeepList) shortcutWidth() int {
+	if l.shortcutColumnWidth > 0 {
+		return l.shortcutColumnWidth
 	}
-
-	return removeItem(index+1, indexes, item.SubList.items)
+	if l.shortcutWidthCached {
+		return l.shortcutWidthCache
+	}
+	var width int
+	for _, item := range l.items {
+		if w := TaggedStringWidth(l.shortcutLabel(item)); w > width {
+			width = w
+		}
+	}
+	if width > 0 {
+		width++
+	}
+	l.shortcutWidthCache, l.shortcutWidthCached = width, true
+	return width
 }
 
-func moveSelectionIndex(index int, indexes []int, items []*deepListItem, change int) []int {
+// shortcutLabel returns the text drawn for the shortcut of the given item or an
+// empty string if it has no shortcut.
+func (l *DeepList) shortcutLabel(item *deepListItem) string {
+	if item.stringShortcut != "" {
+		return "(" + item.stringShortcut + ")"
+	}
+	if item.Shortcut != 0 {
+		return l.formatShortcut(item.Shortcut)
+	}
+	return ""
+}
 
-	item, _ := getItem(0, indexes, items)
+// formatShortcut returns the text drawn for the given shortcut, see
+// SetShortcutFormat().
+func (l *DeepList) formatShortcut(shortcut rune) string {
+	if l.shortcutFormat != nil {
+		return l.shortcutFormat(shortcut)
+	}
+	return fmt.Sprintf("(%s)", string(shortcut))
+}
 
-	log.Printf("item is %s %v \n", item.MainText, item.SubList != nil && item.SubList.display)
+// contentWidth returns the width available to item texts, that is, the inner
+// width without the shortcut column and the scrollbar, given the visible rows.
+func (l *DeepList) contentWidth(rows []deepListRow) int {
+	_, _, width, _ := l.GetInnerRect()
+	width -= l.gutterWidth() + l.scrollbarWidth(rows)
+	if width < 0 {
+		width = 0
+	}
+	return width
+}
 
-	if change > 0 {
-		// go deep or go to next
-		if item.SubList != nil && item.SubList.display && len(item.SubList.items) > 0 {
-			indexes = append(indexes, 0)
+// contentLines returns the number of screen rows needed to draw the given
+// visible rows (as returned by visibleRows()), given the width available to
+// item texts.
+func (l *DeepList) contentLines(rows []deepListRow, width int) int {
+	return l.lineStarts(rows, width)[len(rows)]
+}
 
-			log.Printf("deep : %d \n", indexes)
-		} else {
-			// go to next from parent array
-			indexes[len(indexes)-1] += change
-			log.Printf("increment : %d \n", indexes)
-		}
+// lineStarts returns, for each of the given visible rows (as returned by
+// visibleRows()), the number of screen rows needed to draw the rows before it,
+// given the width available to item texts. An additional last element holds
+// the total. The result is cached until the rows or the layout are invalidated
+// and must not be modified.
+func (l *DeepList) lineStarts(rows []deepListRow, width int) []int {
+	if !l.wrap || len(l.columns) > 0 {
+		width = -1 // Row heights don't depend on the width.
+	}
+	if starts, ok := l.lineStartsCache[width]; ok {
+		return starts
+	}
+	starts := make([]int, len(rows)+1)
+	for index, row := range rows {
+		starts[index+1] = starts[index] + l.rowHeight(row, width)
+	}
+	if l.lineStartsCache == nil || len(l.lineStartsCache) > 2 {
+		// Only a few widths are in use at a time, e.g. with and without the
+		// scrollbar.
+		l.lineStartsCache = make(map[int][]int)
+	}
+	l.lineStartsCache[width] = starts
+	return starts
+}
 
-		return indexes
-	} else {
-		indexes[len(indexes)-1] += change
-		log.Printf("decrement : %d \n", indexes)
-		return indexes
+// gutterWidth returns the total width of the cells next to the item texts
+// which are not available to them, i.e. the item padding, the shortcut column,
+// and the icon column.
+func (l *DeepList) gutterWidth() int {
+	width := l.itemPaddingLeft + l.itemPaddingRight + l.shortcutWidth()
+	if l.iconColumn {
+		width += deepListIconWidth
 	}
+	return width
 }
 
-// TODO: this code wont work properly in complexe case
-func getOffset(total int, index int, indexes []int, items []*deepListItem) int {
-	log.Printf("index : %d %v %d \n", index, indexes, len(items))
-	for _, v := range items[:indexes[index]] {
-		total += 1
-		if v.SubList != nil && v.SubList.display && len(v.SubList.items) > 0 {
-			total = getOffset(total, index+1, indexes, v.SubList.items)
+// scrollbarWidth returns the width of the scrollbar column (0 or 1), given
+// the visible rows.
+func (l *DeepList) scrollbarWidth(rows []deepListRow) int {
+	switch l.scrollbarVisibility {
+	case ScrollbarAlways:
+		return 1
+	case ScrollbarAuto:
+		_, _, width, height := l.GetInnerRect()
+		if l.contentLines(rows, width-l.gutterWidth()-1) > height {
+			return 1
 		}
 	}
-	return total
+	return 0
 }
 
-// TODO: move me
-func equals(ai []int, b []int) bool {
-	for i, v := range b {
-		if ai[i] != v {
-			return false
+// drawScrollbar draws the scrollbar in the given column, reflecting the
+// current item offset.
+func (l *DeepList) drawScrollbar(screen tcell.Screen, rows []deepListRow, x, y, height, width int) {
+	if height <= 0 {
+		return
+	}
+	starts := l.lineStarts(rows, width)
+	totalLines := starts[len(rows)]
+	offsetLines := 0
+	if l.itemOffset < len(rows) {
+		offsetLines = starts[l.itemOffset]
+	}
+	thumbHeight, thumbTop := height, 0
+	if totalLines > height {
+		thumbHeight = height * height / totalLines
+		if thumbHeight < 1 {
+			thumbHeight = 1
+		}
+		thumbTop = offsetLines * (height - thumbHeight) / (totalLines - height)
+		if thumbTop > height-thumbHeight {
+			thumbTop = height - thumbHeight
 		}
 	}
-	return true
+	style := tcell.StyleDefault.Foreground(Styles.GraphicsColor).Background(l.backgroundColor)
+	for row := 0; row < height; row++ {
+		ch := BlockLightShade
+		if row >= thumbTop && row < thumbTop+thumbHeight {
+			ch = BlockFullBlock
+		}
+		screen.SetContent(x, y+row, ch, nil, style)
+	}
 }
 
-// SetCurrentItem sets the currently selected item by its index, starting at 0
-// for the first item. If a negative index is provided, items are referred to
-// from the back (-1 = last item, -2 = second-to-last item, and so on). Out of
-// range indices are clamped to the beginning/end.
-//
-// Calling this function triggers a "changed" event if the selection changes.
-func (l *DeepList) SetCurrentItem(indexes []int) *DeepList {
+// endOffset returns the largest item offset at which the given rows, drawn with
+// the given width, still fill the given height.
+func (l *DeepList) endOffset(rows []deepListRow, width, height int) int {
+	starts := l.lineStarts(rows, width)
+	total := starts[len(rows)]
+	return sort.Search(len(rows), func(offset int) bool {
+		return total-starts[offset] <= height
+	})
+}
 
-	indexes = parseIndexes(0, indexes, l.items)
+// scrollToEnd sets the item offset such that the last rows are shown at the
+// bottom of the list.
+func (l *DeepList) scrollToEnd() {
+	_, _, _, height := l.GetInnerRect()
+	if height <= 0 {
+		return
+	}
+	rows := l.visibleRows()
+	l.itemOffset = l.endOffset(rows, l.contentWidth(rows), height)
+}
 
-	if !equals(indexes, l.currentItem) && l.changed != nil {
-		item, _ := getItem(0, indexes, l.items)
-		l.changed(indexes, item.MainText, item.SecondaryText, item.Shortcut)
+// atEnd returns whether the list is scrolled to its end, i.e. whether its last
+// row is in view.
+func (l *DeepList) atEnd() bool {
+	_, _, _, height := l.GetInnerRect()
+	if height <= 0 {
+		return true
 	}
+	rows := l.visibleRows()
+	return l.itemOffset >= l.endOffset(rows, l.contentWidth(rows), height)
+}
 
-	l.currentItem = indexes
+// updateFollowing suspends following the end of the list (see SetFollow())
+// if the list was scrolled away from its end and resumes it when the list is
+// scrolled back to it.
+func (l *DeepList) updateFollowing() {
+	l.following = l.follow && l.atEnd()
+}
 
-	l.adjustOffset()
+// scrollToLine sets the item offset such that the item drawn at the given
+// position of the scrollbar (0 being the top) becomes the first item drawn.
+// The offset is limited such that the list never scrolls past its last item.
+func (l *DeepList) scrollToLine(position int) {
+	_, _, _, height := l.GetInnerRect()
+	rows := l.visibleRows()
+	if height <= 0 || len(rows) == 0 {
+		return
+	}
+	width := l.contentWidth(rows)
 
-	return l
+	// Determine the largest offset which still fills the list.
+	maxOffset := l.endOffset(rows, width, height)
+
+	starts := l.lineStarts(rows, width)
+	line := position * starts[len(rows)] / height
+	l.itemOffset = sort.Search(maxOffset, func(index int) bool {
+		return starts[index+1] > line
+	})
 }
 
-// GetCurrentItem returns the index of the currently selected list item,
-// starting at 0 for the first item.
-func (l *DeepList) GetCurrentItem() []int {
-	return l.currentItem
+// rowIndent returns the number of cells the given row is indented, given the
+// width available to item texts.
+func rowIndent(row deepListRow, width int) int {
+	indent := (len(row.path) - 1) * deepListIndent
+	if indent > width {
+		indent = width
+	}
+	return indent
 }
 
-// SetOffset sets the number of items to be skipped (vertically) as well as the
-// number of cells skipped horizontally when the list is drawn. Note that one
-// item corresponds to two rows when there are secondary texts. Shortcuts are
-// always drawn.
-//
-// These values may change when the list is drawn to ensure the currently
-// selected item is visible and item texts move out of view. Users can also
-// modify these values by interacting with the list.
-func (l *DeepList) SetOffset(items, horizontal int) *DeepList {
-	l.itemOffset = items
-	l.horizontalOffset = horizontal
-	return l
+// mainTextLines returns the lines of an item's main text when it is drawn with
+// the given width. This is only more than one line if wrapping is enabled.
+func (l *DeepList) mainTextLines(item *deepListItem, width int) []string {
+	text := item.getExpandedMainText(l.tabSize)
+	if !l.wrap || width <= 0 {
+		return []string{text}
+	}
+	lines := WordWrap(text, width)
+	if len(lines) == 0 {
+		return []string{""}
+	}
+	return lines
 }
 
-// GetOffset returns the number of items skipped while drawing, as well as the
-// number of cells item text is moved to the left. See also SetOffset() for more
-// information on these values.
-func (l *DeepList) GetOffset() (int, int) {
-	return l.itemOffset, l.horizontalOffset
+// secondaryTextLines returns the lines of an item's secondary text, which is
+// split at newline characters.
+func secondaryTextLines(item *deepListItem) []string {
+	return strings.Split(item.SecondaryText, "\n")
 }
 
-// RemoveItem removes the item with the given index (starting at 0) from the
-// list. If a negative index is provided, items are referred to from the back
-// (-1 = last item, -2 = second-to-last item, and so on). Out of range indices
-// are clamped to the beginning/end, i.e. unless the list is empty, an item is
-// always removed.
-//
-// The currently selected item is shifted accordingly. If it is the one that is
-// removed, a "changed" event is fired, unless no items are left.
-func (l *DeepList) RemoveItem(indexes []int) *DeepList {
-	if len(l.items) == 0 {
-		return l
+// showsSecondaryText returns whether the secondary text of the given row is
+// drawn. Unless overridden for the item (see SetItemSecondaryVisible()), only
+// top-level items show their secondary text, and only if ShowSecondaryText()
+// is enabled.
+func (l *DeepList) showsSecondaryText(row deepListRow) bool {
+	if row.item.separator || row.item.header {
+		return false
 	}
+	if row.item.secondaryVisibilitySet {
+		return row.item.secondaryVisible
+	}
+	return l.showSecondaryText && len(row.path) == 1
+}
 
-	// Adjust index.
-	indexes = parseIndexes(0, indexes, l.items)
+// childCount returns the number of sub items of the given item, not counting
+// separators and section headers. If total is true, all descendants are
+// counted.
+func childCount(item *deepListItem, total bool) int {
+	if item.SubList == nil {
+		return 0
+	}
+	var count int
+	for _, subItem := range item.SubList.items {
+		if !subItem.separator && !subItem.header {
+			count++
+		}
+		if total {
+			count += childCount(subItem, true)
+		}
+	}
+	return count
+}
 
-	// Remove item.
-	lenAfter := removeItem(0, indexes, l.items)
+// drawColumns draws the tab-separated fields of the given item's main text
+// into the columns set with SetColumns(), starting at the given position. The
+// first column is reduced by the given indentation. Fields which don't fit
+// their column are cut off with an ellipsis. Nothing is drawn beyond the given
+// width. It returns the width covered by the columns.
+func (l *DeepList) drawColumns(screen tcell.Screen, item *deepListItem, x, y, indent, width int, style tcell.Style) int {
+	fields := strings.Split(item.MainText, "\t")
+	columnX := x
+	for index, columnWidth := range l.columns {
+		if index > 0 {
+			columnX++ // A gap between columns.
+		}
+		fieldX, fieldWidth := columnX, columnWidth
+		if index == 0 {
+			fieldX += indent
+			fieldWidth -= indent
+		}
+		if fieldX+fieldWidth > x+width {
+			fieldWidth = x + width - fieldX
+		}
+		if fieldWidth > 0 && index < len(fields) {
+			field := fields[index]
+			_, printedWidth, _, end := printWithStyle(screen, field, fieldX, y, 0, fieldWidth, AlignLeft, style, true)
+			if end < len(field) && printedWidth > 0 {
+				_, _, style, _ := screen.GetContent(fieldX+fieldWidth-1, y)
+				printWithStyle(screen, string(SemigraphicsHorizontalEllipsis), fieldX+fieldWidth-1, y, 0, 1, AlignLeft, style, false)
+			}
+			l.drawSearchHighlight(screen, field, fieldX, y, 0, fieldWidth)
+		}
+		columnX += columnWidth
+		if columnX >= x+width {
+			return width
+		}
+	}
+	return columnX - x
+}
 
-	// If there is nothing left, we're done.
-	if lenAfter == 0 {
-		return l
+// alignment returns the alignment of item texts, taking right-to-left mode
+// into account.
+func (l *DeepList) alignment() int {
+	if l.rtl {
+		return AlignRight
 	}
+	return l.textAlign
+}
 
-	// Shift current item.
-	//previousCurrentItem := l.currentItem
-	//if l.currentItem > index || l.currentItem == len(l.items) {
-	//	l.currentItem--
-	//}
+// alignShift returns the number of cells by which a text of the given width is
+// moved to the right when it is aligned within the given available width
+// according to the list's text alignment.
+func (l *DeepList) alignShift(textWidth, width int) int {
+	if textWidth >= width {
+		return 0
+	}
+	switch l.alignment() {
+	case AlignCenter:
+		return (width - textWidth) / 2
+	case AlignRight:
+		return width - textWidth
+	}
+	return 0
+}
 
-	// Fire "changed" event for removed items.
-	//if previousCurrentItem == index && l.changed != nil {
-	//	item := l.items[l.currentItem]
-	//	l.changed(l.currentItem, item.MainText, item.SecondaryText, item.Shortcut)
-	//}
+// maxItemOffset returns the largest horizontal offset at which the texts of the
+// given row do not move out of view, given the width available to the row's
+// texts.
+func (l *DeepList) maxItemOffset(row deepListRow, width int) int {
+	item := row.item
+	offset := TaggedStringWidth(item.prefix) + item.getMainTextWidth(l.tabSize) - (width - trailingTextWidth(item))
+	if l.showsSecondaryText(row) {
+		for _, line := range secondaryTextLines(item) {
+			if o := TaggedStringWidth(line) - width; o > offset {
+				offset = o
+			}
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
 
-	return l
+// scrollOffset returns the horizontal offset which applies to the selected
+// item: its own offset if horizontal scrolling per item is enabled, the list's
+// offset otherwise.
+func (l *DeepList) scrollOffset() *int {
+	if l.perItemScroll {
+		if item := l.itemAt(l.currentItem); item != nil {
+			return &item.horizontalOffset
+		}
+	}
+	return &l.horizontalOffset
 }
 
-// SetMainTextColor sets the color of the items' main text.
-func (l *DeepList) SetMainTextColor(color tcell.Color) *DeepList {
-	l.mainTextStyle = l.mainTextStyle.Foreground(color)
-	return l
+// trailingTextWidth returns the width reserved for the trailing text of the
+// given item, including a gap before it, or 0 if it has no trailing text.
+func trailingTextWidth(item *deepListItem) int {
+	if item.trailingText == "" {
+		return 0
+	}
+	return TaggedStringWidth(item.trailingText) + 1
 }
 
-// SetMainTextStyle sets the style of the items' main text. Note that the
-// background color is ignored in order not to override the background color of
-// the list itself.
-func (l *DeepList) SetMainTextStyle(style tcell.Style) *DeepList {
-	l.mainTextStyle = style
-	return l
+// rowHeight returns the number of screen rows needed to draw the given row,
+// given the width available to item texts (see contentWidth()).
+func (l *DeepList) rowHeight(row deepListRow, width int) int {
+	height := 1
+	if l.wrap && len(l.columns) == 0 {
+		height = len(l.mainTextLines(row.item, width-rowIndent(row, width)-TaggedStringWidth(row.item.prefix)-trailingTextWidth(row.item)))
+	}
+	if l.showsSecondaryText(row) {
+		height += strings.Count(row.item.SecondaryText, "\n") + 1
+	}
+	return height
 }
 
-// SetSecondaryTextColor sets the color of the items' secondary text.
-func (l *DeepList) SetSecondaryTextColor(color tcell.Color) *DeepList {
-	l.secondaryTextStyle = l.secondaryTextStyle.Foreground(color)
-	return l
+// selectable returns whether the given row can be selected.
+func selectable(row deepListRow) bool {
+	return !row.item.separator && !row.item.header
 }
 
-// SetSecondaryTextStyle sets the style of the items' secondary text. Note that
-// the background color is ignored in order not to override the background color
-// of the list itself.
-func (l *DeepList) SetSecondaryTextStyle(style tcell.Style) *DeepList {
-	l.secondaryTextStyle = style
-	return l
+// firstSelectableChild returns the index of the first row below the row with
+// the given index which belongs to one of its descendants and which can be
+// selected. It returns -1 if there is no such row or if the given index is
+// negative.
+func firstSelectableChild(rows []deepListRow, index int) int {
+	if index < 0 {
+		return -1
+	}
+	depth := len(rows[index].path)
+	for index++; index < len(rows) && len(rows[index].path) > depth; index++ {
+		if selectable(rows[index]) {
+			return index
+		}
+	}
+	return -1
 }
 
-// SetShortcutColor sets the color of the items' shortcut.
-func (l *DeepList) SetShortcutColor(color tcell.Color) *DeepList {
-	l.shortcutStyle = l.shortcutStyle.Foreground(color)
-	return l
+// selectableAncestor returns the path of the closest ancestor of the item at
+// the given path which can be selected, skipping section headers (which may
+// have sub items, see AddSubItem()). It returns nil if there is none.
+func (l *DeepList) selectableAncestor(path []int) []int {
+	for depth := len(path) - 1; depth > 0; depth-- {
+		if l.selectablePath(path[:depth]) {
+			return append([]int(nil), path[:depth]...)
+		}
+	}
+	return nil
 }
 
-// SetShortcutStyle sets the style of the items' shortcut. Note that the
-// background color is ignored in order not to override the background color of
-// the list itself.
-func (l *DeepList) SetShortcutStyle(style tcell.Style) *DeepList {
-	l.shortcutStyle = style
-	return l
+// isBackspace returns whether the given key is one of the two keys which
+// terminals send for Backspace.
+func isBackspace(key tcell.Key) bool {
+	return key == tcell.KeyBackspace || key == tcell.KeyBackspace2
 }
 
-// SetSelectedTextColor sets the text color of selected items. Note that the
-// color of main text characters that are different from the main text color
-// (e.g. color tags) is maintained.
-func (l *DeepList) SetSelectedTextColor(color tcell.Color) *DeepList {
-	l.selectedStyle = l.selectedStyle.Foreground(color)
-	return l
+// shortcutRow returns the index of the first of the given rows which can be
+// selected with the given shortcut, or -1 if there is no such row. As only the
+// shortcuts of top-level items are drawn, only these are considered. If several
+// items have the same shortcut, the topmost one wins.
+func shortcutRow(rows []deepListRow, shortcut rune) int {
+	for index, row := range rows {
+		if len(row.path) == 1 && selectable(row) && row.item.Shortcut == shortcut {
+			return index
+		}
+	}
+	return -1
+}
+
+// matchShortcut processes a typed rune and returns the index of the row whose
+// shortcut was typed, or -1 if there is none. Runes are collected to match
+// string shortcuts. If the runes typed so far are the beginning of a string
+// shortcut of a visible top-level item, pending is true and further keys are
+// awaited. Single-rune shortcuts have precedence over string shortcuts starting
+// with the same rune.
+func (l *DeepList) matchShortcut(rows []deepListRow, ch rune) (index int, pending bool) {
+	if time.Since(l.shortcutTime) > shortcutTimeout {
+		l.shortcutBuffer = ""
+	}
+	if l.shortcutBuffer == "" {
+		if index := shortcutRow(rows, ch); index >= 0 {
+			return index, false
+		}
+	}
+
+	sequence := l.shortcutBuffer + string(ch)
+	l.shortcutBuffer = ""
+	for index, row := range rows {
+		if len(row.path) != 1 || !selectable(row) || row.item.stringShortcut == "" {
+			continue
+		}
+		if row.item.stringShortcut == sequence {
+			return index, false
+		}
+		if strings.HasPrefix(row.item.stringShortcut, sequence) {
+			pending = true
+		}
+	}
+	if pending {
+		l.shortcutBuffer = sequence
+		l.shortcutTime = time.Now()
+		return -1, true
+	}
+	if sequence != string(ch) {
+		// The sequence broke off. Start over with this rune.
+		return l.matchShortcut(rows, ch)
+	}
+	return -1, false
+}
+
+// selectablePath returns whether the item at the given path exists and can be
+// selected.
+func (l *DeepList) selectablePath(path []int) bool {
+	item := l.itemAt(path)
+	return item != nil && selectable(deepListRow{path: path, item: item})
+}
+
+// selectableRow returns the index of the first row which can be selected,
+// starting at the given index and continuing in the given direction (1 or -1).
+// If wrap is true, the search continues at the other end of the rows.
+// Otherwise, if there is no such row in the given direction, the search
+// continues from the start index in the other direction. It returns -1 if no
+// row can be selected.
+func selectableRow(rows []deepListRow, start, direction int, wrap bool) int {
+	for i := 0; i < len(rows); i++ {
+		index := start + i*direction
+		if wrap {
+			index = ((index % len(rows)) + len(rows)) % len(rows)
+		} else if index < 0 || index >= len(rows) {
+			break
+		}
+		if selectable(rows[index]) {
+			return index
+		}
+	}
+	if !wrap {
+		for index := start - direction; index >= 0 && index < len(rows); index -= direction {
+			if selectable(rows[index]) {
+				return index
+			}
+		}
+	}
+	return -1
+}
+
+// moveSelection moves the selection by the given number of visible items,
+// downwards for positive values and upwards for negative values. Items are
+// counted in the order in which they are drawn, including the items of
+// displayed sublists at any depth. Items which cannot be selected (e.g.
+// separators) are skipped. If wrap is true, moving down past the last visible
+// item selects the first item and moving up past the first item selects the
+// last visible item, even if it is a sub item. Otherwise, the selection stops
+// at the first or last item.
+func (l *DeepList) moveSelection(change int, wrap bool) {
+	rows := l.visibleRows()
+	if len(rows) == 0 || change == 0 {
+		return
+	}
+	index := l.currentRowIndex(rows)
+	if index < 0 {
+		index = 0
+	}
+	direction := 1
+	if change < 0 {
+		direction = -1
+	}
+	index += change
+	if index < 0 {
+		index = 0
+		if wrap {
+			index = len(rows) - 1
+		}
+	} else if index >= len(rows) {
+		index = len(rows) - 1
+		if wrap {
+			index = 0
+		}
+	}
+	if index = selectableRow(rows, index, direction, wrap); index >= 0 {
+		l.currentItem = append([]int(nil), rows[index].path...)
+		l.currentRow = index
+	}
+}
+
+// movePage moves the selection by one page of screen rows, downwards for a
+// positive direction and upwards for a negative one. Items taller than one row
+// (e.g. because their secondary text is shown or their text wraps) count with
+// their full height. The selected item is the one drawn at the row one page
+// below or above the start of the current item, but the selection always moves
+// by at least one item. It stops at the first or last item.
+func (l *DeepList) movePage(direction int) {
+	_, _, _, height := l.GetInnerRect()
+	rows := l.visibleRows()
+	if height <= 0 || len(rows) == 0 {
+		return
+	}
+	index := l.currentRowIndex(rows)
+	if index < 0 {
+		index = 0
+	}
+	starts := l.lineStarts(rows, l.contentWidth(rows))
+	line := starts[index] + direction*height
+	target := sort.Search(len(rows), func(row int) bool {
+		return starts[row+1] > line
+	})
+	if target == index {
+		target += direction
+	}
+	l.moveSelection(target-index, false)
+}
+
+// equals returns whether the two given paths have the same length and the same
+// indices.
+func equals(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range b {
+		if a[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleRows returns all items whose ancestors' sublists are displayed, in the
+// order in which they are drawn. If a filter is set, only items which match it
+// or which have a matching descendant are included.
+//
+// The result is cached until invalidateRows() is called. It must not be
+// modified.
+func (l *DeepList) visibleRows() []deepListRow {
+	if l.rowsValid {
+		return l.rows
+	}
+	var rows []deepListRow
+	var walk func(parent []int, items []*deepListItem)
+	walk = func(parent []int, items []*deepListItem) {
+		for index, item := range items {
+			path := append(parent[:len(parent):len(parent)], index)
+			if l.filter != nil && !l.matchesFilter(path, item) {
+				continue
+			}
+			rows = append(rows, deepListRow{path: path, item: item})
+			if l.subListShown(path, item) {
+				walk(path, item.SubList.items)
+			}
+		}
+	}
+	walk(nil, l.items)
+	l.rows, l.rowsValid = rows, true
+	return rows
+}
+
+// subListShown returns whether the sub items of the given item, found at the
+// given path, are visible, i.e. whether its sublist is displayed and the item
+// is above the maximum visible depth. Filters are not taken into account.
+func (l *DeepList) subListShown(path []int, item *deepListItem) bool {
+	return item.SubList != nil && item.SubList.display && l.aboveMaxDepth(path)
+}
+
+// aboveMaxDepth returns whether items at the given path may show their sub
+// items, given the maximum visible depth.
+func (l *DeepList) aboveMaxDepth(path []int) bool {
+	return l.maxVisibleDepth <= 0 || len(path) < l.maxVisibleDepth
+}
+
+// invalidateRows marks the cached visible rows as outdated such that they are
+// rebuilt the next time they are needed. This includes the layout, see
+// invalidateLayout().
+func (l *DeepList) invalidateRows() {
+	l.rows, l.rowsValid = nil, false
+	l.invalidateLayout()
+}
+
+// invalidateLayout marks the cached row heights and the shortcut column width
+// as outdated, e.g. after a text they depend on changed.
+func (l *DeepList) invalidateLayout() {
+	l.lineStartsCache = nil
+	l.shortcutWidthCached = false
+}
+
+// currentRowIndex returns the index of the selected item's row among the given
+// visible rows, or -1 if the item is not visible. The index found last is
+// checked first, so repeated lookups don't have to search all rows.
+func (l *DeepList) currentRowIndex(rows []deepListRow) int {
+	if l.currentRow >= 0 && l.currentRow < len(rows) && equals(rows[l.currentRow].path, l.currentItem) {
+		return l.currentRow
+	}
+	l.currentRow = rowIndex(rows, l.currentItem)
+	return l.currentRow
+}
+
+// matchesFilter returns whether the item at the given path or any of its
+// descendants (including those in hidden sublists) match the filter.
+func (l *DeepList) matchesFilter(path []int, item *deepListItem) bool {
+	if l.filter(path, item.MainText, item.SecondaryText, item.Shortcut) {
+		return true
+	}
+	if item.SubList != nil {
+		for index, subItem := range item.SubList.items {
+			if l.matchesFilter(append(path[:len(path):len(path)], index), subItem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moveToSibling moves the selection to the closest visible sibling in the given
+// direction (1 or -1) which can be selected, cycling through the siblings if
+// wrapping is enabled. It returns whether the selection changed.
+func (l *DeepList) moveToSibling(rows []deepListRow, direction int) bool {
+	count := l.siblingCount(l.currentItem)
+	if count == 0 {
+		return false
+	}
+	path := append([]int(nil), l.currentItem...)
+	last := len(path) - 1
+	start := path[last]
+	for step := 1; step < count; step++ {
+		index := start + step*direction
+		if l.wrapAround {
+			index = ((index % count) + count) % count
+		} else if index < 0 || index >= count {
+			return false
+		}
+		path[last] = index
+		if row := rowIndex(rows, path); row >= 0 && selectable(rows[row]) {
+			l.currentItem = path
+			return true
+		}
+	}
+	return false
+}
+
+// fireBoundary invokes the "boundary" callback with the given direction if
+// wrapping is disabled and the selection is still the given previous item
+// after trying to move it.
+func (l *DeepList) fireBoundary(previous []int, direction int) {
+	if l.boundary != nil && !l.wrapAround && equals(previous, l.currentItem) {
+		handler := l.boundary
+		l.unlocked(func() { handler(direction) })
+	}
+}
+
+// fireChanged invokes the "changed" callback for the current item. During
+// batch updates, the callback is postponed until the batch ends.
+func (l *DeepList) fireChanged() {
+	if l.multiSelect {
+		if l.extendingSelection {
+			l.extendingSelection = false
+		} else {
+			l.collapseSelection()
+		}
+		l.fireSelectionChanged()
+	}
+	if l.batching {
+		l.batchChanged = true
+		return
+	}
+	item := l.itemAt(l.currentItem)
+	if item == nil {
+		return
+	}
+	l.updateTitle()
+	previous := l.reportedItem
+	l.reportedItem = append([]int(nil), l.currentItem...)
+
+	// The callbacks receive copies as they must not alias the current item
+	// which is modified in place, e.g. when items are inserted.
+	mainText, secondaryText, shortcut := item.MainText, item.SecondaryText, item.Shortcut
+	if l.changed != nil {
+		handler, current := l.changed, append([]int(nil), l.currentItem...)
+		l.unlocked(func() { handler(current, mainText, secondaryText, shortcut) })
+	}
+	if l.changedEx != nil {
+		handler, current := l.changedEx, append([]int(nil), l.currentItem...)
+		l.unlocked(func() { handler(previous, current, mainText, secondaryText, shortcut) })
+	}
+}
+
+// unlocked calls the given function with the list's mutex unlocked so that
+// callbacks may call the list's methods. The mutex must be locked.
+func (l *DeepList) unlocked(fn func()) {
+	l.mutex.Unlock()
+	defer l.mutex.Lock()
+	fn()
+}
+
+// BatchUpdate calls the given function which may modify the list, e.g. by
+// adding many items. While the function runs, "changed" events are suppressed
+// and the vertical offset is not adjusted. Afterwards, a single "changed" event
+// is fired if any were suppressed, and the offset is adjusted once. Calls to
+// BatchUpdate() may be nested, only the outermost call ends the batch.
+func (l *DeepList) BatchUpdate(fn func()) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.batching {
+		l.unlocked(fn)
+		return l
+	}
+	l.batching, l.batchChanged = true, false
+	func() {
+		defer func() {
+			l.batching = false
+		}()
+		l.unlocked(fn)
+	}()
+	if l.batchChanged {
+		l.batchChanged = false
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return l
+}
+
+// SetCurrentItem sets the currently selected item by its index, starting at 0
+// for the first item. If a negative index is provided, items are referred to
+// from the back (-1 = last item, -2 = second-to-last item, and so on). Out of
+// range indices are clamped to the beginning/end, see ValidatePath(). The
+// sublists of the item's ancestors are displayed.
+//
+// Calling this function triggers a "changed" event if the selection changes.
+// See SetCurrentItemQuietly() for a variant which doesn't.
+func (l *DeepList) SetCurrentItem(indexes []int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.setCurrentItem(indexes, true)
+	return l
+}
+
+// SetCurrentItemQuietly sets the currently selected item like SetCurrentItem()
+// but never triggers a "changed" event, e.g. to restore a saved selection or
+// to change the selection from within the "changed" callback without causing
+// a feedback loop.
+func (l *DeepList) SetCurrentItemQuietly(indexes []int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.setCurrentItem(indexes, false)
+	return l
+}
+
+// setCurrentItem implements SetCurrentItem() and SetCurrentItemQuietly(). A
+// "changed" event is fired only if notify is true.
+func (l *DeepList) setCurrentItem(indexes []int, notify bool) {
+	indexes, _ = parseIndexes(indexes, l.items)
+	if !l.aboveMaxDepth(indexes[:len(indexes)-1]) {
+		// Hidden by the maximum visible depth. Select the visible ancestor.
+		indexes = indexes[:l.maxVisibleDepth]
+	}
+
+	changed := !equals(indexes, l.currentItem)
+	if changed {
+		// Display ancestor sublists.
+		for depth := 1; depth < len(indexes); depth++ {
+			l.setExpanded(indexes[:depth], true)
+		}
+		l.invalidateRows()
+	}
+
+	l.currentItem = indexes
+	if changed && notify {
+		l.fireChanged()
+	}
+
+	l.adjustOffset()
+}
+
+// GetCurrentItem returns the path of the currently selected list item, starting
+// at 0 for the first item. The returned slice is a copy which may be modified.
+func (l *DeepList) GetCurrentItem() []int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]int(nil), l.currentItem...)
+}
+
+// CurrentItemHasChildren returns true if the currently selected item has a
+// sublist with at least one item. False is returned if no item is selected.
+func (l *DeepList) CurrentItemHasChildren() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	item := l.itemAt(l.currentItem)
+	return item != nil && item.SubList != nil && len(item.SubList.items) > 0
+}
+
+// CurrentItemExpanded returns true if the currently selected item has a
+// sublist with at least one item and that sublist is displayed. False is
+// returned if no item is selected.
+func (l *DeepList) CurrentItemExpanded() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	item := l.itemAt(l.currentItem)
+	return item != nil && item.SubList != nil && len(item.SubList.items) > 0 && item.SubList.display
+}
+
+// SetOffset sets the number of items to be skipped (vertically) as well as the
+// number of cells skipped horizontally when the list is drawn. Items are
+// counted in the order in which they are drawn, including the items of
+// displayed sublists. Note that one top-level item corresponds to two rows when
+// there are secondary texts. Shortcuts are always drawn.
+//
+// These values may change when the list is drawn to ensure the currently
+// selected item is visible and item texts move out of view. Users can also
+// modify these values by interacting with the list.
+func (l *DeepList) SetOffset(items, horizontal int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.itemOffset = items
+	l.horizontalOffset = horizontal
+	return l
+}
+
+// GetOffset returns the number of items skipped while drawing, as well as the
+// number of cells item text is moved to the left. See also SetOffset() for more
+// information on these values.
+func (l *DeepList) GetOffset() (int, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.itemOffset, l.horizontalOffset
+}
+
+// ScrollHorizontal moves the texts by the given number of cells to the left
+// (or to the right for negative values), like the left and right arrow keys.
+// The offset is clamped such that the texts don't move out of view. With
+// horizontal scrolling per item (see SetPerItemHorizontalScroll()), only the
+// selected item is scrolled.
+func (l *DeepList) ScrollHorizontal(cells int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	offset := l.scrollOffset()
+	*offset += cells
+	if maxOffset := l.maxHorizontalOffset(); *offset > maxOffset {
+		*offset = maxOffset
+	}
+	if *offset < 0 || l.ellipsis {
+		*offset = 0
+	}
+	return l
+}
+
+// SetHorizontalScrollStep sets the number of cells by which the left and right
+// arrow keys scroll the item texts horizontally when they don't fit the list.
+// With the Shift key held down, the texts scroll by half the list's width (or
+// by the step, if it is larger). The default step of 2 cells avoids stopping
+// in the middle of two-cell characters. Values smaller than 1 are treated as
+// 1.
+func (l *DeepList) SetHorizontalScrollStep(step int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if step < 1 {
+		step = 1
+	}
+	l.scrollStep = step
+	return l
+}
+
+// scrollJump returns the number of cells by which an arrow key with the given
+// modifiers scrolls the item texts horizontally.
+func (l *DeepList) scrollJump(modifiers tcell.ModMask) int {
+	if modifiers&tcell.ModShift != 0 {
+		if _, _, width, _ := l.GetInnerRect(); width/2 > l.scrollStep {
+			return width / 2
+		}
+	}
+	return l.scrollStep
+}
+
+// ResetHorizontalScroll scrolls the texts back to their beginning. With
+// horizontal scrolling per item (see SetPerItemHorizontalScroll()), only the
+// selected item is affected.
+func (l *DeepList) ResetHorizontalScroll() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	*l.scrollOffset() = 0
+	return l
+}
+
+// GetHorizontalOffset returns the number of cells by which the texts of the
+// selected item are moved to the left. Unless horizontal scrolling per item is
+// enabled (see SetPerItemHorizontalScroll()), this is the same for all items.
+func (l *DeepList) GetHorizontalOffset() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return *l.scrollOffset()
+}
+
+// maxHorizontalOffset returns the largest horizontal offset at which the texts
+// of the selected item (with horizontal scrolling per item) or of any visible
+// item (otherwise) do not all move out of view.
+func (l *DeepList) maxHorizontalOffset() int {
+	rows := l.visibleRows()
+	width := l.contentWidth(rows)
+	var maxOffset int
+	for _, row := range rows {
+		if l.perItemScroll && !equals(row.path, l.currentItem) {
+			continue
+		}
+		if offset := l.maxItemOffset(row, width-rowIndent(row, width)); offset > maxOffset {
+			maxOffset = offset
+		}
+	}
+	return maxOffset
+}
+
+// RemoveItem removes the item at the given path, together with its sub items.
+// If a negative index is provided, items are referred to from the back (-1 =
+// last item, -2 = second-to-last item, and so on). Out of range indices are
+// clamped to the beginning/end, see ValidatePath(), i.e. unless the list is
+// empty, an item is always removed.
+//
+// The currently selected item is shifted accordingly. If it is the removed item
+// or one of its sub items, the selection moves to the removed item's next
+// sibling or, if there is none, to its previous sibling or, if there is none
+// either, to its parent, and a "changed" event is fired. Separators and section
+// headers are skipped when looking for a sibling. If no items are left,
+// the selection is reset to the first item without a "changed" event.
+func (l *DeepList) RemoveItem(indexes []int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.removePath(indexes)
+	return l
+}
+
+// removePath implements RemoveItem().
+func (l *DeepList) removePath(indexes []int) {
+	if len(l.items) == 0 {
+		return
+	}
+	l.saveUndo()
+
+	// Adjust index.
+	indexes, _ = parseIndexes(indexes, l.items)
+	depth := len(indexes) - 1
+	index := indexes[depth]
+
+	// Remove item.
+	if depth == 0 {
+		l.items = removeItem(l.items, index)
+	} else {
+		parent := l.itemAt(indexes[:depth])
+		parent.SubList.items = removeItem(parent.SubList.items, index)
+	}
+	l.invalidateRows()
+
+	// If there is nothing left, we're done.
+	if len(l.items) == 0 {
+		l.currentItem = []int{0}
+		return
+	}
+
+	// Shift current item.
+	if len(l.currentItem) > depth && equals(l.currentItem[:depth], indexes[:depth]) {
+		current := append([]int(nil), l.currentItem...)
+		switch {
+		case current[depth] > index:
+			current[depth]--
+			l.currentItem = current
+		case current[depth] == index:
+			// The selected item was removed.
+			l.currentItem = l.closestSelectable(append(current[:depth], index))
+			l.fireChanged()
+		}
+	}
+	l.normalizeCurrentItem()
+
+	l.adjustOffset()
+}
+
+// RemoveItemsFunc removes all items for which the given function returns true,
+// together with their sub items, and returns the number of removed items
+// (including sub items). The function receives the path of each item (as it was
+// before anything was removed) as well as its texts and shortcut. It is not
+// called for the sub items of removed items.
+//
+// The selection is updated once, after all items were removed. If the selected
+// item (or one of its ancestors) was removed, the selection moves as described
+// for RemoveItem(), to the closest remaining sibling or to the parent, and a
+// "changed" event is fired.
+func (l *DeepList) RemoveItemsFunc(predicate func(path []int, mainText, secondaryText string, shortcut rune) bool) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Find the items to be removed.
+	removed := make(map[*deepListItem]bool)
+	var count int
+	var countItems func(item *deepListItem) int
+	countItems = func(item *deepListItem) int {
+		count := 1
+		if item.SubList != nil {
+			for _, subItem := range item.SubList.items {
+				count += countItems(subItem)
+			}
+		}
+		return count
+	}
+	var walk func(parent []int, items []*deepListItem)
+	walk = func(parent []int, items []*deepListItem) {
+		for index, item := range items {
+			path := append(parent[:len(parent):len(parent)], index)
+			if predicate(path, item.MainText, item.SecondaryText, item.Shortcut) {
+				removed[item] = true
+				count += countItems(item)
+			} else if item.SubList != nil {
+				walk(path, item.SubList.items)
+			}
+		}
+	}
+	walk(nil, l.items)
+	if count == 0 {
+		return 0
+	}
+
+	// Determine the new selection: the selected item if it remains, otherwise
+	// the position of its removed ancestor, from which the closest remaining
+	// sibling or parent is selected below. Indices are shifted by the number
+	// of removed siblings before them.
+	var selection []int
+	var selectionRemoved bool
+	items := l.items
+	for _, index := range l.currentItem {
+		if index < 0 || index >= len(items) {
+			break
+		}
+		if removed[items[index]] {
+			selectionRemoved = true
+			selection = append(selection, shiftedIndex(items, index, removed))
+			break
+		}
+		selection = append(selection, shiftedIndex(items, index, removed))
+		if items[index].SubList == nil {
+			break
+		}
+		items = items[index].SubList.items
+	}
+
+	// Remove the items.
+	l.saveUndo()
+	var filter func(items []*deepListItem) []*deepListItem
+	filter = func(items []*deepListItem) []*deepListItem {
+		remaining := items[:0]
+		for _, item := range items {
+			if removed[item] {
+				continue
+			}
+			if item.SubList != nil {
+				item.SubList.items = filter(item.SubList.items)
+			}
+			remaining = append(remaining, item)
+		}
+		for index := len(remaining); index < len(items); index++ {
+			items[index] = nil
+		}
+		return remaining
+	}
+	l.items = filter(l.items)
+	l.invalidateRows()
+
+	// If there is nothing left, we're done.
+	if len(l.items) == 0 {
+		l.currentItem = []int{0}
+		return count
+	}
+
+	if len(selection) == 0 {
+		selection = []int{0}
+	}
+	if selectionRemoved {
+		selection = l.closestSelectable(selection)
+	}
+	l.currentItem = selection
+	l.normalizeCurrentItem()
+	if selectionRemoved {
+		l.fireChanged()
+	}
+	l.adjustOffset()
+
+	return count
+}
+
+// shiftedIndex returns the index which the item with the given index will have
+// once the items marked as removed are removed from the given items.
+func shiftedIndex(items []*deepListItem, index int, removed map[*deepListItem]bool) int {
+	shifted := index
+	for _, item := range items[:index] {
+		if removed[item] {
+			shifted--
+		}
+	}
+	return shifted
+}
+
+// SetMainTextColor sets the color of the items' main text.
+func (l *DeepList) SetMainTextColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.mainTextStyle = l.mainTextStyle.Foreground(color)
+	return l
+}
+
+// SetMainTextStyle sets the style of the items' main text. Note that the
+// background color is ignored in order not to override the background color of
+// the list itself.
+func (l *DeepList) SetMainTextStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.mainTextStyle = style
+	return l
+}
+
+// SetSecondaryTextColor sets the color of the items' secondary text.
+func (l *DeepList) SetSecondaryTextColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.secondaryTextStyle = l.secondaryTextStyle.Foreground(color)
+	return l
+}
+
+// SetSecondaryTextStyle sets the style of the items' secondary text. Note that
+// the background color is ignored in order not to override the background color
+// of the list itself.
+func (l *DeepList) SetSecondaryTextStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.secondaryTextStyle = style
+	return l
+}
+
+// SetShortcutColor sets the color of the items' shortcut.
+func (l *DeepList) SetShortcutColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.shortcutStyle = l.shortcutStyle.Foreground(color)
+	return l
+}
+
+// SetShortcutFormat sets the function which returns the text drawn for an
+// item's shortcut, e.g. "[a[]" or "a.". The text may contain color tags. The
+// shortcut column is sized to fit the widest formatted shortcut (see also
+// SetShortcutWidth()). If no such function is set (or nil is provided),
+// shortcuts are drawn as "(a)".
+func (l *DeepList) SetShortcutFormat(format func(shortcut rune) string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.shortcutFormat = format
+	l.invalidateLayout()
+	return l
+}
+
+// SetShortcutWidth sets the width of the column in which shortcuts are drawn,
+// including the gap of one cell before the item texts. Shortcuts which don't
+// fit are cut off. With a width of 0 (the default), the column fits the widest
+// formatted shortcut and is omitted if no top-level item has a shortcut.
+func (l *DeepList) SetShortcutWidth(width int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if width < 0 {
+		width = 0
+	}
+	l.shortcutColumnWidth = width
+	return l
+}
+
+// SetItemPadding sets the number of empty cells left and right of each item's
+// contents, i.e. outside the shortcut and icon columns and the item texts. Row
+// backgrounds (alternating colors or the hover highlight) include the padding,
+// the scrollbar does not. The default is no padding.
+func (l *DeepList) SetItemPadding(left, right int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if left < 0 {
+		left = 0
+	}
+	if right < 0 {
+		right = 0
+	}
+	l.itemPaddingLeft, l.itemPaddingRight = left, right
+	return l
+}
+
+// SetShortcutStyle sets the style of the items' shortcut. Note that the
+// background color is ignored in order not to override the background color of
+// the list itself.
+func (l *DeepList) SetShortcutStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.shortcutStyle = style
+	return l
+}
+
+// SetDepthStyle sets the styles of the main and secondary texts of items at
+// the given depth (0 for top-level items, 1 for their sub items, and so on),
+// overriding the list-wide styles. See also ClearDepthStyles().
+func (l *DeepList) SetDepthStyle(depth int, main, secondary tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.depthStyles == nil {
+		l.depthStyles = make(map[int]deepListDepthStyle)
+	}
+	l.depthStyles[depth] = deepListDepthStyle{main: main, secondary: secondary}
+	return l
+}
+
+// GetDepthStyle returns the styles of the main and secondary texts set for
+// items at the given depth with SetDepthStyle(). If no styles were set for
+// this depth, ok is false.
+func (l *DeepList) GetDepthStyle(depth int) (main, secondary tcell.Style, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	style, ok := l.depthStyles[depth]
+	return style.main, style.secondary, ok
+}
+
+// ClearDepthStyles removes all styles set with SetDepthStyle() such that all
+// items are drawn with the list-wide styles again.
+func (l *DeepList) ClearDepthStyles() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.depthStyles = nil
+	return l
+}
+
+// SetDimAncestors sets a flag which determines whether the items leading to
+// the selected item, i.e. its parent, its parent's parent, and so on, are
+// drawn in the ancestor style (see SetAncestorStyle()) to show where the
+// selection is in the tree. Other items are not affected.
+func (l *DeepList) SetDimAncestors(dim bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.dimAncestors = dim
+	return l
+}
+
+// SetAncestorStyle sets the style of the main and secondary texts of the
+// selected item's ancestors when enabled with SetDimAncestors(). It takes
+// precedence over depth styles.
+func (l *DeepList) SetAncestorStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.ancestorStyle = style
+	return l
+}
+
+// rowStyles returns the styles of the main and secondary texts of the given
+// row. Without a depth style, top-level items use the main text style for their
+// main text while sub items use the secondary text style. Ancestors of the
+// selected item use the ancestor style if enabled with SetDimAncestors().
+func (l *DeepList) rowStyles(row deepListRow) (main, secondary tcell.Style) {
+	if row.item.header {
+		return l.headerStyle, l.secondaryTextStyle
+	}
+	if l.dimAncestors && len(row.path) < len(l.currentItem) && equals(l.currentItem[:len(row.path)], row.path) {
+		return l.ancestorStyle, l.ancestorStyle
+	}
+	depth := len(row.path) - 1
+	if style, ok := l.depthStyles[depth]; ok {
+		return style.main, style.secondary
+	}
+	if depth > 0 {
+		return l.secondaryTextStyle, l.secondaryTextStyle
+	}
+	return l.mainTextStyle, l.secondaryTextStyle
+}
+
+// SetSelectedTextColor sets the text color of selected items. Note that the
+// color of main text characters that are different from the main text color
+// (e.g. color tags) is maintained.
+func (l *DeepList) SetSelectedTextColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectedStyle = l.selectedStyle.Foreground(color)
+	return l
 }
 
 // SetSelectedBackgroundColor sets the background color of selected items.
 func (l *DeepList) SetSelectedBackgroundColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
 	l.selectedStyle = l.selectedStyle.Background(color)
 	return l
 }
 
-// SetSelectedStyle sets the style of the selected items. Note that the color of
-// main text characters that are different from the main text color (e.g. color
-// tags) is maintained.
-func (l *DeepList) SetSelectedStyle(style tcell.Style) *DeepList {
-	l.selectedStyle = style
+// SetSelectedStyle sets the style of the selected items. Note that the color of
+// main text characters that are different from the main text color (e.g. color
+// tags) is maintained.
+func (l *DeepList) SetSelectedStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectedStyle = style
+	return l
+}
+
+// SetSelectedFocusOnly sets a flag which determines when the currently selected
+// list item is highlighted. If set to true, selected items are only highlighted
+// when the list has focus. If set to false, they are always highlighted.
+func (l *DeepList) SetSelectedFocusOnly(focusOnly bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectedFocusOnly = focusOnly
+	return l
+}
+
+// SetHighlightFullLine sets a flag which determines whether the colored
+// background of selected items spans the entire width of the view. If set to
+// true, the highlight spans the entire view. If set to false, only the text of
+// the selected item from beginning to end is highlighted.
+func (l *DeepList) SetHighlightFullLine(highlight bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.highlightFullLine = highlight
+	return l
+}
+
+// SetAlternateRowColor sets the background color of every other visible item,
+// which makes dense lists easier to read. Items are counted in the order in
+// which they are drawn (including displayed sub items), so the striping stays
+// consistent when sublists are shown or hidden. The selected item's highlight
+// takes precedence. Provide tcell.ColorDefault to disable striping.
+func (l *DeepList) SetAlternateRowColor(color tcell.Color) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.alternateRowColor = color
+	return l
+}
+
+// SetWrap sets a flag which determines whether main texts which are wider than
+// the available space are wrapped onto multiple lines (at the item's
+// indentation) instead of being cut off. Wrapped main texts are not scrolled
+// horizontally.
+func (l *DeepList) SetWrap(wrap bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.wrap = wrap
+	l.invalidateLayout()
+	return l
+}
+
+// SetPerItemHorizontalScroll sets a flag which determines whether each item
+// remembers its own horizontal offset, e.g. for long log lines. If enabled, the
+// left and right arrow keys only scroll the selected item, and the offset given
+// to SetOffset() is ignored. Otherwise (the default), all items are scrolled
+// together.
+func (l *DeepList) SetPerItemHorizontalScroll(perItem bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.perItemScroll = perItem
+	return l
+}
+
+// SetColumns sets the widths of columns into which the main texts of all items
+// are laid out, turning the list into a tree table. The main text is split
+// into fields at tab characters ("\t"), the first field is drawn into the first
+// column, the second field into the second column, and so on, with a gap of one
+// cell between columns. Fields which don't fit their column are cut off with an
+// ellipsis; fields without a column are not drawn. The first column includes
+// the items' indentation and prefixes, so the following columns are aligned
+// for all items. Color tags don't carry over from one field to the next. In
+// column mode, main texts are neither wrapped, scrolled horizontally, nor
+// aligned, and no child counts or spinners are drawn. The selection highlight
+// spans all columns.
+//
+// Provide nil or an empty slice to draw main texts as they are.
+func (l *DeepList) SetColumns(widths []int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.columns = append([]int(nil), widths...)
+	l.invalidateLayout()
+	return l
+}
+
+// SetTabSize sets the number of cells between tab stops in the items' main
+// texts. Tab characters ("\t") are replaced with spaces up to the next tab
+// stop, counted from the start of the main text, so that tab-separated parts
+// of main texts are aligned across items at the same depth. The default is the
+// value of TabSize at the time the list was created. Sizes below 1 are treated
+// as 1. Tabs are not expanded in column mode where they separate the fields,
+// see SetColumns().
+func (l *DeepList) SetTabSize(size int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.tabSize = size
+	var walk func(items []*deepListItem)
+	walk = func(items []*deepListItem) {
+		for _, item := range items {
+			item.mainTextWidthValid = false
+			if item.SubList != nil {
+				walk(item.SubList.items)
+			}
+		}
+	}
+	walk(l.items)
+	l.invalidateLayout()
+	return l
+}
+
+// SetTextAlign sets the alignment of the items' main and secondary texts, one
+// of AlignLeft (the default), AlignCenter, or AlignRight. Texts are aligned
+// within the width remaining after the shortcut and icon columns and the item's
+// indentation and before the item's trailing text (see SetItemTrailingText()).
+// Prefixes and child counts move along with the main text.
+func (l *DeepList) SetTextAlign(align int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.textAlign = align
+	return l
+}
+
+// SetRTL sets a flag which determines whether items are laid out from right to
+// left, e.g. for Hebrew or Arabic texts. If enabled, main and secondary texts
+// are right-aligned (regardless of SetTextAlign()), sub items are indented from
+// the right edge, and the shortcut and icon columns are drawn at the right edge.
+// Section headers are right-aligned, too.
+//
+// This mode only mirrors the layout of items. The characters of a text are
+// still drawn in their logical order, from left to right, and strings mixing
+// both directions are not reordered (there is no bidirectional algorithm), so
+// the display of right-to-left scripts depends on the terminal. Prefixes,
+// trailing texts, and columns (see SetColumns()) keep their left-to-right
+// placement.
+func (l *DeepList) SetRTL(rtl bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rtl = rtl
+	return l
+}
+
+// SetEmptyText sets a text which is drawn in the given style, centered in the
+// list's inner area, when the list has no items, e.g. "No items". The text may
+// contain color tags. Set it to an empty string to draw nothing (the default).
+func (l *DeepList) SetEmptyText(text string, style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.emptyText = text
+	l.emptyTextStyle = style
+	return l
+}
+
+// SetEllipsis sets a flag which determines whether main and secondary texts
+// which are wider than the available space are cut off with an ellipsis ("…")
+// at the right edge. This disables horizontal scrolling: The right and left
+// arrow keys then act as if no text overflowed. When combined with SetWrap(),
+// only secondary texts are cut off.
+func (l *DeepList) SetEllipsis(ellipsis bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.ellipsis = ellipsis
+	return l
+}
+
+// SetMaxVisibleDepth sets the number of levels of the item tree which are
+// drawn at most, e.g. 1 to show only top-level items. Items deeper than that
+// are hidden as if their parents were collapsed, regardless of whether their
+// sublists are displayed, and cannot be navigated to. Items at the maximum
+// depth still show the number of their sub items if enabled with
+// SetShowChildCount(). A depth of 0 (the default) removes the limit.
+//
+// If the currently selected item is hidden, its visible ancestor is selected
+// and a "changed" event is fired.
+func (l *DeepList) SetMaxVisibleDepth(depth int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.maxVisibleDepth = depth
+	l.invalidateRows()
+	if !l.aboveMaxDepth(l.currentItem[:len(l.currentItem)-1]) && l.selectAncestor(l.currentItem[:depth]) {
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return l
+}
+
+// SetTreeNavigation sets a flag which determines whether the left and right
+// arrow keys are used to navigate the item tree. If enabled, the right arrow key
+// displays the sublist of a collapsed item and moves to the first sub item of
+// an expanded item, skipping separators and section headers. The left arrow
+// key hides the sublist of an expanded item and moves from any other item to
+// its parent, or to the closest other ancestor if the parent is a section
+// header. On items without sub items, the right arrow key behaves as if tree
+// navigation was disabled, as does the left arrow key on items without such an
+// ancestor, e.g. top-level items.
+func (l *DeepList) SetTreeNavigation(treeNavigation bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.treeNavigation = treeNavigation
+	return l
+}
+
+// SetVimKeys sets a flag which determines whether Vim-style keys navigate the
+// list: "j" and "k" act like the down and up arrow keys, "g" and "G" like the
+// Home and End keys, and "h" and "l" like the left and right arrow keys with
+// tree navigation enabled (see SetTreeNavigation()), i.e. they collapse and
+// expand sublists and move between items and their sub items (but never
+// scroll horizontally or move to the previous or next item). While enabled,
+// these keys are not used as shortcuts or for the type-ahead search. Vim keys
+// are disabled by default.
+func (l *DeepList) SetVimKeys(vimKeys bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.vimKeys = vimKeys
+	return l
+}
+
+// SetScrollbarVisibility sets when a vertical scrollbar is drawn along the
+// right edge of the list's inner area: never (ScrollbarNever, the default),
+// only if the visible items don't fit (ScrollbarAuto), or always
+// (ScrollbarAlways). The scrollbar occupies one column which is then not
+// available to item texts. Its thumb can be dragged with the mouse.
+func (l *DeepList) SetScrollbarVisibility(visibility ScrollbarVisibility) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.scrollbarVisibility = visibility
+	return l
+}
+
+// SetSelectedAlwaysVisible sets how the list scrolls to keep the selected item
+// in view. With SelectedVisibleEdge (the default), the list only scrolls when
+// the selection moves past the top or bottom edge of the list. With
+// SelectedVisibleCenter, the selected item is kept at the vertical center of
+// the list's inner area, except near the beginning and the end of the list,
+// where the list doesn't scroll further than needed to fill its area.
+func (l *DeepList) SetSelectedAlwaysVisible(mode SelectedVisibility) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectedVisibility = mode
+	l.adjustOffset()
+	return l
+}
+
+// ShowSecondaryText determines whether or not to show the secondary texts of
+// top-level items. This can be overridden for individual items with
+// SetItemSecondaryVisible().
+func (l *DeepList) ShowSecondaryText(show bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.showSecondaryText = show
+	l.invalidateLayout()
+	return l
+}
+
+// SetWrapAround sets the flag that determines whether navigating the list will
+// wrap around. That is, navigating downwards on the last visible item (which
+// may be an item of a displayed sublist) will move the selection to the first
+// item and navigating upwards on the first item will move the selection to the
+// last visible item. If set to false, the selection won't change when
+// navigating downwards on the last item or navigating upwards on the first
+// item.
+func (l *DeepList) SetWrapAround(wrapAround bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.wrapAround = wrapAround
+	return l
+}
+
+// SetChangedFunc sets the function which is called when the user navigates to
+// a list item. The function receives the item's index in the list of items
+// (starting with 0), its main text, secondary text, and its shortcut rune.
+//
+// This function is also called when the first item is added or when
+// SetCurrentItem() is called. It may modify the list, too, including its
+// selection. Note that changing the selection fires another "changed" event,
+// see SetCurrentItemQuietly() to avoid that.
+func (l *DeepList) SetChangedFunc(handler func(indexes []int, mainText string, secondaryText string, shortcut rune)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.changed = handler
+	return l
+}
+
+// SetChangedFuncEx sets a function which is called whenever the function set
+// with SetChangedFunc() is called (after it, if both are set). In addition to
+// the path and texts of the newly selected item, it receives the path which was
+// reported by the previous call (or the initial path), e.g. to detect when the
+// selection enters or leaves a sublist.
+func (l *DeepList) SetChangedFuncEx(handler func(previous, current []int, mainText, secondaryText string, shortcut rune)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.changedEx = handler
+	return l
+}
+
+// SetSelectedFunc sets the function which is called when the user selects a
+// list item by pressing Enter or Space on the current selection, by typing the
+// item's shortcut, or by double-clicking an item without sub items (a single
+// click only moves the selection). The function receives the item's path
+// (starting with 0 for the first item), its main text, secondary text, and its
+// shortcut rune. It is called after the item's own callback, or even if the
+// item has none. If the item was selected with its shortcut and the selection
+// moved, the "changed" event is fired first.
+//
+// The function may modify the list, e.g. remove the selected item. The list
+// uses its state after the call, and any functions called, e.g.
+// SetCurrentItem() or RemoveItem(), fire their events as usual.
+func (l *DeepList) SetSelectedFunc(handler func([]int, string, string, rune)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selected = handler
+	return l
+}
+
+// SetDoneFunc sets a function which is called when the user presses the Escape
+// key.
+func (l *DeepList) SetDoneFunc(handler func()) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.done = handler
+	return l
+}
+
+// SetDoneFuncWithSelection sets a function which is called when the user
+// presses the Escape key, like the function set with SetDoneFunc() (which is
+// called first if both are set). It receives the path and texts of the
+// selected item, or nil and empty values if the list has no items.
+func (l *DeepList) SetDoneFuncWithSelection(handler func(index []int, mainText, secondaryText string, shortcut rune)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.doneWithSelection = handler
+	return l
+}
+
+// SetClipboardFunc sets a function which is called with the text of the
+// selected item when the user presses the copy key (see SetCopyKey()). As this
+// package does not access the system clipboard, the function is expected to
+// write the text to it. Color tags are removed from the text. Without this
+// function, the copy key has no effect.
+func (l *DeepList) SetClipboardFunc(handler func(text string)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.clipboard = handler
+	return l
+}
+
+// SetCopyKey sets the key which copies the text of the selected item using the
+// function set with SetClipboardFunc(). The default is Ctrl-Y.
+func (l *DeepList) SetCopyKey(key tcell.Key) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.copyKey = key
+	return l
+}
+
+// SetHelpFunc sets a function which receives the help texts of items (see
+// SetItemHelp()), e.g. to show them in a tooltip. Rendering the help text is up
+// to the function, GetItemRect() helps to place it next to the item.
+//
+// The function is called with the path and the help text of the selected item
+// when the user presses the help key (see SetHelpKey()). If mouse hover is
+// enabled (see SetMouseHover()), it is also called when the mouse moves onto an
+// item with a help text, and with a nil path and an empty help text when the
+// mouse moves off such an item onto another item, e.g. to hide the tooltip.
+// When the mouse leaves the list, the function is not called until the mouse
+// moves over the list again, see SetMouseHover().
+func (l *DeepList) SetHelpFunc(handler func(path []int, help string)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.help = handler
+	return l
+}
+
+// SetHelpKey sets the key (a printable character) which requests the help text
+// of the selected item, see SetHelpFunc(). The default is '?'. The key is only
+// consumed if the selected item has a help text, otherwise it is handled like
+// any other key, e.g. as a shortcut. Set it to 0 to disable the help key.
+func (l *DeepList) SetHelpKey(key rune) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.helpKey = key
+	return l
+}
+
+// hoverHelp calls the help handler when the mouse moves from the item at the
+// previous path to the item at the given path, with the new item's help text
+// or, if the new item has none but the previous item had one, with a nil path
+// and an empty help text.
+func (l *DeepList) hoverHelp(previous, path []int) {
+	if l.help == nil || equals(previous, path) {
+		return
+	}
+	if item := l.itemAt(path); item != nil && item.help != "" {
+		path = append([]int(nil), path...)
+		handler, help := l.help, item.help
+		l.unlocked(func() { handler(path, help) })
+	} else if item := l.itemAt(previous); item != nil && item.help != "" {
+		handler := l.help
+		l.unlocked(func() { handler(nil, "") })
+	}
+}
+
+// SetCopySecondaryText sets a flag which determines whether the copy key
+// copies the selected item's secondary text, on a separate line, along with its
+// main text. By default, only the main text is copied.
+func (l *DeepList) SetCopySecondaryText(include bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.copySecondaryText = include
+	return l
+}
+
+// SetBoundaryFunc sets a function which is called when the user tries to move
+// the selection past the first or last item with the Up or Down keys (or Tab
+// and Backtab) while wrapping is disabled (see SetWrapAround()), e.g. to flash
+// the border. The direction is -1 for the top and 1 for the bottom.
+func (l *DeepList) SetBoundaryFunc(handler func(direction int)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.boundary = handler
+	return l
+}
+
+// SetParentKey sets the key which moves the selection from a sub item to its
+// parent item, regardless of whether tree navigation is enabled (see
+// SetTreeNavigation()). Section headers are skipped in favor of the closest
+// other ancestor. No sublist is hidden. The key has no effect on top-level
+// items. The default is Backspace (which, during a type-ahead search,
+// removes the last typed character instead). Set to 0 (tcell.KeyNUL) to disable
+// this key.
+func (l *DeepList) SetParentKey(key tcell.Key) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.parentKey = key
+	return l
+}
+
+// SetSiblingKeys sets the keys which move the selection to the next or
+// previous sibling of the selected item, i.e. the next or previous item in the
+// same list, skipping over any displayed sub items. The keys must be pressed
+// with exactly the given modifiers, e.g. SetSiblingKeys(tcell.KeyDown,
+// tcell.KeyUp, tcell.ModCtrl) for Ctrl+Down and Ctrl+Up. At the last or first
+// sibling, the keys have no effect unless wrapping is enabled (see
+// SetWrapAround()), in which case they cycle through the siblings. Siblings
+// which are hidden by the filter or which cannot be selected are skipped.
+//
+// These keys are disabled by default. Set both keys to 0 (tcell.KeyNUL) to
+// disable them again.
+func (l *DeepList) SetSiblingKeys(next, previous tcell.Key, modifiers tcell.ModMask) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.siblingKeys = next != 0 || previous != 0
+	l.nextSiblingKey, l.previousSiblingKey = next, previous
+	l.siblingModifiers = modifiers
+	return l
+}
+
+// SetRightClickFunc sets a function which is called when the user clicks the
+// list with the right mouse button, e.g. to show a context menu. It receives
+// the path of the item under the mouse cursor (nil if there is no item) and
+// the screen coordinates of the click. The selection is not changed.
+func (l *DeepList) SetRightClickFunc(handler func(path []int, x, y int)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rightClick = handler
+	return l
+}
+
+// SetTypeAhead sets a flag which determines whether typing letters which are
+// not item shortcuts moves the selection to the next visible item whose main
+// text starts with the typed letters (case-insensitive). Letters typed in quick
+// succession are accumulated, the search text is reset after a second without
+// typing. Backspace removes the last typed letter.
+func (l *DeepList) SetTypeAhead(typeAhead bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.typeAhead = typeAhead
+	l.typeAheadBuffer = ""
+	return l
+}
+
+// typeAheadSearch moves the selection to the first visible item, starting at
+// the current item, whose main text starts with the type-ahead buffer. If next
+// is true, the search starts at the item following the current item. The
+// selection remains unchanged if there is no such item.
+func (l *DeepList) typeAheadSearch(next bool) {
+	if l.typeAheadBuffer == "" {
+		return
+	}
+	rows := l.visibleRows()
+	start := l.currentRowIndex(rows)
+	if start < 0 {
+		start = 0
+	}
+	if next {
+		start++
+	}
+	search := strings.ToLower(l.typeAheadBuffer)
+	for i := range rows {
+		row := rows[(start+i)%len(rows)]
+		if selectable(row) && strings.HasPrefix(strings.ToLower(stripTags(row.item.MainText)), search) {
+			l.currentItem = append([]int(nil), row.path...)
+			return
+		}
+	}
+}
+
+// SetFilter sets a function which determines which items are shown. It
+// receives an item's path, main text, secondary text, and shortcut and returns
+// whether the item should be shown. Items which don't match are hidden, unless
+// one of their descendants matches, in which case they remain visible so the
+// matching descendant can be reached. Drawing and navigation only consider the
+// items shown, and so does GetVisibleItemCount(). Hidden items keep their
+// paths, so functions which address items by path or index, including
+// GetItemCount() and GetSiblingCount(), still count all items.
+//
+// If the selected item is hidden by the filter, the first shown item is
+// selected, triggering a "changed" event. Provide nil to remove the filter and
+// show all items again.
+//
+// The filter's results are cached until the items change. If the filter
+// depends on other state (e.g. a search text), call this function again when
+// that state changes.
+func (l *DeepList) SetFilter(filter func(path []int, mainText, secondaryText string, shortcut rune) bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.filter = filter
+	l.invalidateRows()
+	rows := l.visibleRows()
+	if index := selectableRow(rows, 0, 1, false); index >= 0 && l.currentRowIndex(rows) < 0 {
+		l.currentItem = append([]int(nil), rows[index].path...)
+		l.fireChanged()
+	}
+	l.itemOffset = 0
+	l.adjustOffset()
+	return l
+}
+
+// SetSearchHighlight sets a text whose occurrences in the items' main and
+// secondary texts are drawn in the given style. Color tags in item texts are
+// not searched. If the style has no background color, the background of the
+// highlighted text is left unchanged, e.g. maintaining the background of the
+// selected item. Provide an empty string to remove all highlights.
+func (l *DeepList) SetSearchHighlight(query string, style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.searchHighlight = query
+	l.searchHighlightStyle = style
+	return l
+}
+
+// SetSearchHighlightIgnoreCase sets a flag which determines whether the search
+// text set with SetSearchHighlight() is matched case-insensitively.
+func (l *DeepList) SetSearchHighlightIgnoreCase(ignoreCase bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.searchHighlightNoCase = ignoreCase
+	return l
+}
+
+// highlightSelection applies the selected style to the given number of cells,
+// starting at the given position. The foreground color of characters whose
+// color differs from the given text style (e.g. due to color tags) is
+// maintained.
+func (l *DeepList) highlightSelection(screen tcell.Screen, x, y, width int, textStyle tcell.Style) {
+	textColor, _, _ := textStyle.Decompose()
+	for bx := 0; bx < width; bx++ {
+		m, c, style, _ := screen.GetContent(x+bx, y)
+		fg, _, _ := style.Decompose()
+		style = l.selectedStyle
+		if fg != textColor {
+			style = style.Foreground(fg)
+		}
+		screen.SetContent(x+bx, y, m, c, style)
+	}
+}
+
+// drawSearchHighlight applies the search highlight style to all occurrences of
+// the search text in the given text which was printed at the given position,
+// skipping the given number of cells and not exceeding the given width.
+func (l *DeepList) drawSearchHighlight(screen tcell.Screen, text string, x, y, skipWidth, width int) {
+	if l.searchHighlight == "" {
+		return
+	}
+	text = stripTags(text)
+	search, query := text, l.searchHighlight
+	if l.searchHighlightNoCase {
+		search, query = strings.ToLower(search), strings.ToLower(query)
+	}
+
+	// Find the byte ranges of all occurrences.
+	var matches [][2]int
+	for start := 0; start < len(search); {
+		index := strings.Index(search[start:], query)
+		if index < 0 {
+			break
+		}
+		matches = append(matches, [2]int{start + index, start + index + len(query)})
+		start += index + len(query)
+	}
+	if len(matches) == 0 {
+		return
+	}
+
+	highlightFg, highlightBg, highlightAttr := l.searchHighlightStyle.Decompose()
+	iterateString(text, func(main rune, comb []rune, textPos, textWidth, screenPos, screenWidth, boundaries int) bool {
+		for len(matches) > 0 && textPos >= matches[0][1] {
+			matches = matches[1:]
+		}
+		if len(matches) == 0 {
+			return true
+		}
+		if textPos < matches[0][0] {
+			return false
+		}
+		col := screenPos - skipWidth
+		if col < 0 || col >= width {
+			return false
+		}
+		m, c, style, _ := screen.GetContent(x+col, y)
+		_, bg, _ := style.Decompose()
+		if highlightBg != tcell.ColorDefault {
+			bg = highlightBg
+		}
+		screen.SetContent(x+col, y, m, c, tcell.StyleDefault.Foreground(highlightFg).Background(bg).Attributes(highlightAttr))
+		return false
+	})
+}
+
+// SetExpandedFunc sets a function which is called when the sublist of an item
+// is displayed or hidden, e.g. by ToggleSubListDisplay(), ExpandAll(),
+// CollapseAll(), ApplyExpansionState(), or tree navigation keys. The function
+// receives the item's path and whether its sublist is now displayed. Bulk
+// operations call it once for each item whose display state changed.
+func (l *DeepList) SetExpandedFunc(handler func(path []int, expanded bool)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.expanded = handler
+	return l
+}
+
+// AddItem calls InsertItem() with an index of -1.
+func (l *DeepList) AddItem(mainText, secondaryText string, shortcut rune, selected func()) *DeepList {
+	l.InsertItem(-1, mainText, secondaryText, shortcut, selected)
+	return l
+}
+
+// AddSubItem adds a new item to the end of the sublist of the last top-level
+// item, creating the sublist if needed. Nothing happens if the list is empty.
+// See InsertItem() for a description of the item's texts, shortcut, and
+// "selected" callback. The display flag determines whether the sublist is shown
+// or hidden and applies to all of its items, not just the new one.
+//
+// Unlike InsertItem(), this function does not change the selection or fire a
+// "changed" event in most cases. There are two exceptions: If the sublist is
+// hidden (display is false) while the selected item is one of its items, their
+// parent becomes selected. If no selectable item was selected before (e.g.
+// because the list only contained section headers) and the sublist is shown,
+// the first selectable item becomes selected. A "changed" event is fired in
+// both cases.
+func (l *DeepList) AddSubItem(mainText, secondaryText string, shortcut rune, display bool, selected func()) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	lastIndex := len(l.items) - 1
+	if lastIndex < 0 {
+		return l
+	}
+
+	item := &deepListItem{
+		MainText:      mainText,
+		SecondaryText: secondaryText,
+		Shortcut:      shortcut,
+		Selected:      selected,
+	}
+
+	l.appendSubItem(item).display = display
+
+	// Fire a "changed" event if the selection had to move.
+	if current := l.itemAt(l.currentItem); !display && l.selectAncestor([]int{lastIndex}) {
+		l.fireChanged()
+		l.adjustOffset()
+	} else if display && (current == nil || current.separator || current.header) {
+		rows := l.visibleRows()
+		if index := selectableRow(rows, 0, 1, false); index >= 0 {
+			l.currentItem = append([]int(nil), rows[index].path...)
+			l.fireChanged()
+			l.adjustOffset()
+		}
+	}
+	if l.following {
+		l.scrollToEnd()
+	}
+
+	return l
+}
+
+// appendSubItem appends the given item to the sublist of the last top-level
+// item, creating a hidden sublist if there is none yet. It returns the
+// sublist. The list must not be empty.
+func (l *DeepList) appendSubItem(item *deepListItem) *subList {
+	return l.appendChild(l.items[len(l.items)-1], item)
+}
+
+// appendChild appends the given item to the sublist of the given parent item,
+// creating a hidden sublist if there is none yet. It returns the sublist.
+func (l *DeepList) appendChild(parentItem, item *deepListItem) *subList {
+	l.saveUndo()
+	if parentItem.SubList == nil {
+		parentItem.SubList = &subList{}
+	}
+	parentItem.SubList.items = append(parentItem.SubList.items, item)
+	l.invalidateRows()
+	l.normalizeCurrentItem()
+	return parentItem.SubList
+}
+
+// AddSeparator calls InsertSeparator() with an index of -1.
+func (l *DeepList) AddSeparator() *DeepList {
+	return l.InsertSeparator(-1)
+}
+
+// InsertSeparator inserts a separator at the given top-level index (see
+// InsertItem() for how the index is interpreted). A separator is drawn as a
+// horizontal line of the rune set with SetSeparatorRune(). It cannot be
+// selected, navigation skips over it.
+func (l *DeepList) InsertSeparator(index int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.insertItem(index, &deepListItem{separator: true})
+	return l
+}
+
+// AddSubSeparator adds a separator to the sublist of the last top-level item,
+// similar to AddSubItem(). The display state of the sublist is not changed.
+func (l *DeepList) AddSubSeparator() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.items) > 0 {
+		l.appendSubItem(&deepListItem{separator: true})
+	}
+	return l
+}
+
+// AddHeader adds a section header to the end of the top-level items. A header
+// shows the given text in the style set with SetHeaderStyle(). It cannot be
+// selected, navigation and shortcuts skip over it. See also
+// SetStickyHeaders().
+func (l *DeepList) AddHeader(text string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.insertItem(-1, &deepListItem{MainText: text, header: true})
+	return l
+}
+
+// AddSubHeader adds a section header to the sublist of the last top-level
+// item, similar to AddSubItem(). The display state of the sublist is not
+// changed. See AddHeaderPath() for headers in deeper sublists.
+func (l *DeepList) AddSubHeader(text string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.items) > 0 {
+		l.appendSubItem(&deepListItem{MainText: text, header: true})
+	}
+	return l
+}
+
+// AddHeaderPath adds a section header to the end of the sublist of the item at
+// the given path, creating a hidden sublist if there is none yet, so headers
+// can be added at any depth. The display state of the sublist is not changed.
+// Nothing happens if the path does not lead to an existing item.
+func (l *DeepList) AddHeaderPath(path []int, text string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if parent := l.itemAt(path); parent != nil {
+		l.appendChild(parent, &deepListItem{MainText: text, header: true})
+	}
+	return l
+}
+
+// SetHeaderStyle sets the style of section headers.
+func (l *DeepList) SetHeaderStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.headerStyle = style
+	return l
+}
+
+// SetItemPrefix sets a short string which is drawn immediately before the main
+// text of the item at the given path, after its indentation, e.g. a status
+// marker. The prefix may contain color tags and is drawn in the prefix style
+// (see SetPrefixStyle()). An empty prefix removes it. Nothing happens if the
+// path is invalid.
+func (l *DeepList) SetItemPrefix(path []int, prefix string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.prefix = prefix
+		l.invalidateLayout()
+	}
+	return l
+}
+
+// GetItemPrefix returns the prefix of the item at the given path, as set with
+// SetItemPrefix(). It returns an empty string if the path is invalid.
+func (l *DeepList) GetItemPrefix(path []int) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		return item.prefix
+	}
+	return ""
+}
+
+// SetItemTrailingText sets a text which is drawn right-aligned at the right
+// edge of the item at the given path, e.g. a count or status badge, in the
+// given style. The main text ends before the trailing text and is cut off with
+// an ellipsis if it does not fit. The trailing text is not scrolled
+// horizontally. When the item is selected, the trailing text keeps its
+// style but takes on the background of the selection if the full line is
+// highlighted. An empty text removes the trailing text. Nothing happens if the
+// path is invalid.
+func (l *DeepList) SetItemTrailingText(path []int, text string, style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.trailingText = text
+		item.trailingStyle = style
+		l.invalidateLayout()
+	}
+	return l
+}
+
+// SetItemHelp sets a help text for the item at the given path, e.g. an
+// explanation of what the item does. The list does not draw help texts, it
+// passes them to the function set with SetHelpFunc(). An empty text removes the
+// item's help text. Nothing happens if the path is invalid.
+func (l *DeepList) SetItemHelp(path []int, help string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.help = help
+	}
+	return l
+}
+
+// SetItemReference stores a reference of any type in the item at the given
+// path, e.g. the domain object the item represents. Callbacks which receive the
+// item's path, such as the "selected" and "changed" callbacks, can retrieve it
+// with GetItemReference(). Nothing happens if the path is invalid.
+func (l *DeepList) SetItemReference(path []int, reference interface{}) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.reference = reference
+	}
+	return l
+}
+
+// GetItemReference returns the reference stored in the item at the given path
+// with SetItemReference(), or nil if there is none or if the path does not lead
+// to an existing item.
+func (l *DeepList) GetItemReference(path []int) interface{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		return item.reference
+	}
+	return nil
+}
+
+// SetItemIcon sets the icon of the item at the given path, e.g. a file type
+// glyph, and its style. Icons are drawn in a dedicated column left of the item
+// texts which is aligned for all items regardless of their depth. The column
+// must be enabled with SetIconColumn(). An icon of 0 removes the item's icon.
+// Nothing happens if the path is invalid.
+func (l *DeepList) SetItemIcon(path []int, icon rune, style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.icon = icon
+		item.iconStyle = style
+	}
+	return l
+}
+
+// SetIconColumn sets a flag which determines whether a column for item icons
+// (see SetItemIcon()) is reserved left of the item texts. Items without an
+// icon leave the column blank.
+func (l *DeepList) SetIconColumn(show bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.iconColumn = show
+	return l
+}
+
+// SetPrefixStyle sets the style of item prefixes.
+func (l *DeepList) SetPrefixStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.prefixStyle = style
+	return l
+}
+
+// SetStickyHeaders sets a flag which determines whether a section header
+// remains visible at the top of the list while the items following it are
+// scrolled. If enabled, the header whose section contains the first drawn item
+// is drawn in the first row when the header itself has been scrolled out of
+// view. A section consists of the items following a header in the same list as
+// the header (including their sub items), up to the next header.
+func (l *DeepList) SetStickyHeaders(sticky bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.stickyHeaders = sticky
+	return l
+}
+
+// stickyHeader returns the row of the header which is to be drawn at the top
+// of the list given the current item offset, or nil if there is none.
+func (l *DeepList) stickyHeader(rows []deepListRow) *deepListRow {
+	if !l.stickyHeaders || l.itemOffset <= 0 || l.itemOffset >= len(rows) {
+		return nil
+	}
+	if rows[l.itemOffset].item.header {
+		return nil // The header is visible anyway.
+	}
+	first := rows[l.itemOffset].path
+	for index := l.itemOffset - 1; index >= 0; index-- {
+		row := rows[index]
+		parent := row.path[:len(row.path)-1]
+		if len(parent) >= len(first) || !equals(first[:len(parent)], parent) {
+			continue // Not in a list containing the first drawn item.
+		}
+		if row.item.header {
+			return &rows[index]
+		}
+	}
+	return nil
+}
+
+// SetSeparatorRune sets the rune used to draw separators.
+func (l *DeepList) SetSeparatorRune(separator rune) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.separatorRune = separator
+	return l
+}
+
+// SetTitleFollowsSelection sets a flag which determines whether the box title
+// shows the path of the selected item as a breadcrumb, i.e. the main texts of
+// the item and its ancestors joined with the given separator, for example
+// "Root / Folder / File" with the separator " / ". The title is updated with
+// every "changed" event and whenever the list is drawn. When disabled, the
+// title is left as it is.
+func (l *DeepList) SetTitleFollowsSelection(follow bool, separator string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.titleFollowsSelection, l.titleSeparator = follow, separator
+	l.updateTitle()
+	return l
+}
+
+// updateTitle sets the box title to the path of the selected item if enabled
+// with SetTitleFollowsSelection().
+func (l *DeepList) updateTitle() {
+	if !l.titleFollowsSelection {
+		return
+	}
+	var title string
+	if l.itemAt(l.currentItem) != nil {
+		title = strings.Join(l.pathTexts(l.currentItem), l.titleSeparator)
+	}
+	l.Box.SetTitle(title)
+}
+
+// SetIndentRune sets the rune which fills the indentation of sub items, e.g.
+// '·' to mark their depth, instead of leaving it blank. Each level of
+// indentation is two cells wide, so the rune must be a single-cell character.
+// Set it to 0 (the default) for blank indentation. The rune is drawn in the
+// indent style, see SetIndentStyle().
+func (l *DeepList) SetIndentRune(indent rune) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.indentRune = indent
+	return l
+}
+
+// SetIndentStyle sets the style of the rune which fills the indentation of sub
+// items, see SetIndentRune(). The background color of the row is kept.
+func (l *DeepList) SetIndentStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.indentStyle = style
+	return l
+}
+
+// setExpanded shows or hides the sublist of the item at the given path,
+// invoking the "expanded" callback if the display state changed. It returns
+// true if the item has a sublist whose display state was changed.
+func (l *DeepList) setExpanded(path []int, expanded bool) bool {
+	item := l.itemAt(path)
+	if item == nil || item.SubList == nil || item.SubList.display == expanded {
+		return false
+	}
+	item.SubList.display = expanded
+	if !expanded && l.collapseResetsDescendants {
+		collapseDescendants(item.SubList.items)
+	}
+	l.invalidateRows()
+	if l.expanded != nil {
+		path = append([]int(nil), path...)
+		handler := l.expanded
+		l.unlocked(func() { handler(path, expanded) })
+	}
+	return true
+}
+
+// ToggleSubListDisplay shows or hides the sublist of the top-level item with
+// the given index. See also ToggleSubListDisplayPath().
+func (l *DeepList) ToggleSubListDisplay(index int) *DeepList {
+	return l.ToggleSubListDisplayPath([]int{index})
+}
+
+// ToggleSubListDisplayPath shows the sublist of the item at the given path if
+// it is hidden, and hides it if it is displayed. If the hidden sublist contains
+// the selected item, the item owning the sublist is selected instead,
+// triggering a "changed" event. Nothing happens if the path does not lead to an
+// existing item or if the item has no sublist.
+func (l *DeepList) ToggleSubListDisplayPath(path []int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.toggleSubListDisplay(path)
+	return l
+}
+
+// toggleSubListDisplay implements ToggleSubListDisplayPath().
+func (l *DeepList) toggleSubListDisplay(path []int) {
+	item := l.itemAt(path)
+	if item == nil || item.SubList == nil {
+		return
+	}
+
+	if l.toggleExpanded(path, !item.SubList.display) {
+		l.fireChanged()
+		l.adjustOffset()
+	}
+}
+
+// toggleExpanded displays or hides the sublist of the item at the given path
+// like setExpanded(). When hiding the sublist, a selection inside of it is
+// moved onto the item. When displaying it, the selection is moved onto the
+// first sub item if enabled with SetSelectChildOnExpand(). It returns whether
+// the selection changed. The "changed" callback is not called.
+func (l *DeepList) toggleExpanded(path []int, expanded bool) bool {
+	path = append([]int(nil), path...)
+	if !l.setExpanded(path, expanded) {
+		return false
+	}
+	if !expanded {
+		// Move the selection out of the hidden sublist.
+		return l.selectAncestor(path)
+	}
+
+	var changed bool
+	if l.accordion {
+		// Hide the sublists of the item's siblings.
+		sibling := append([]int(nil), path...)
+		for index := 0; index < l.siblingCount(path); index++ {
+			if index == path[len(path)-1] {
+				continue
+			}
+			sibling[len(sibling)-1] = index
+			if l.setExpanded(sibling, false) && l.selectAncestor(sibling) {
+				changed = true
+			}
+		}
+	}
+	if !l.selectChildOnExpand {
+		return changed
+	}
+
+	// Select the first child which can be selected.
+	rows := l.visibleRows()
+	if child := firstSelectableChild(rows, rowIndex(rows, path)); child >= 0 {
+		l.currentItem = append([]int(nil), rows[child].path...)
+		return true
+	}
+	return changed
+}
+
+// selectAncestor moves the selection onto the item at the given path if the
+// selected item is one of its descendants. It returns whether the selection
+// changed.
+func (l *DeepList) selectAncestor(path []int) bool {
+	if len(l.currentItem) > len(path) && equals(l.currentItem[:len(path)], path) {
+		l.currentItem = append([]int(nil), path...)
+		return true
+	}
+	return false
+}
+
+// SetLoading sets a flag which determines whether the item at the given path
+// shows a spinner after its main text, e.g. while its sub items are loaded in
+// the background. The spinner advances by one frame every time the list is
+// drawn, so the application needs to redraw regularly while items are loading.
+// From a goroutine, loading could be driven like this:
+//
+//	list.SetLoading(path, true)
+//	go func() {
+//	    ticker := time.NewTicker(100 * time.Millisecond)
+//	    defer ticker.Stop()
+//	    done := loadChildren(path) // A channel closed when loading is done.
+//	    for {
+//	        select {
+//	        case <-ticker.C:
+//	            app.Draw()
+//	        case <-done:
+//	            app.QueueUpdateDraw(func() {
+//	                // Add sub items here.
+//	                list.SetLoading(path, false)
+//	            })
+//	            return
+//	        }
+//	    }
+//	}()
+//
+// As with all changes of the list made from other goroutines, SetLoading()
+// must be called from a function passed to Application.QueueUpdateDraw() once
+// the list is drawn by an application. Nothing happens if the path is invalid.
+func (l *DeepList) SetLoading(path []int, loading bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.loading = loading
+	}
+	return l
+}
+
+// SetShowChildCount sets a flag which determines whether items with a hidden
+// sublist show the number of their sub items, e.g. "Folder (12)", after their
+// main text. Separators and section headers are not counted. See also
+// SetChildCountTotal() and SetChildCountStyle().
+func (l *DeepList) SetShowChildCount(show bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.showChildCount = show
+	return l
+}
+
+// SetChildCountTotal sets a flag which determines whether the count shown with
+// SetShowChildCount() includes all descendants of an item instead of only its
+// direct sub items.
+func (l *DeepList) SetChildCountTotal(total bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.childCountTotal = total
+	return l
+}
+
+// SetChildCountStyle sets the style of the counts shown with
+// SetShowChildCount().
+func (l *DeepList) SetChildCountStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.childCountStyle = style
+	return l
+}
+
+// SetAccordion sets a flag which determines whether displaying the sublist of
+// an item with ToggleSubListDisplay(), ToggleSubListDisplayPath(), tree
+// navigation keys, or a double click hides the sublists of the item's siblings,
+// such that at most one branch is open per list. The "expanded" callback is
+// called for each sibling whose sublist is hidden. The selection stays on the
+// item unless it was inside a sibling's hidden sublist: It then moves onto that
+// sibling (or, with SetSelectChildOnExpand(), onto the item's first sub item).
+// ExpandAll() and ApplyExpansionState() are not affected.
+func (l *DeepList) SetAccordion(accordion bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.accordion = accordion
+	return l
+}
+
+// SetSelectChildOnExpand sets a flag which determines whether displaying the
+// sublist of an item with ToggleSubListDisplay(), ToggleSubListDisplayPath(),
+// tree navigation keys, or a double click moves the selection onto the item's
+// first sub item. The "changed" callback is called when the selection moves.
+// Regardless of this flag, hiding a sublist which contains the selected item
+// always moves the selection onto the item owning the sublist.
+func (l *DeepList) SetSelectChildOnExpand(selectChild bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectChildOnExpand = selectChild
+	return l
+}
+
+// SetCollapseResetsDescendants sets a flag which determines whether hiding the
+// sublist of an item, e.g. with ToggleSubListDisplayPath() or the tree
+// navigation keys, also hides the sublists of all of its descendants. When the
+// item is expanded again, only its direct sub items are shown. Otherwise (the
+// default), the descendants keep their display state. The "expanded" callback
+// is only called for the item itself.
+func (l *DeepList) SetCollapseResetsDescendants(reset bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.collapseResetsDescendants = reset
+	return l
+}
+
+// collapseDescendants hides the sublists of the given items and of all their
+// descendants.
+func collapseDescendants(items []*deepListItem) {
+	for _, item := range items {
+		if item.SubList != nil {
+			item.SubList.display = false
+			collapseDescendants(item.SubList.items)
+		}
+	}
+}
+
+// ExpandAll displays the sublists of all items. The "expanded" callback is
+// invoked for each sublist which was hidden before.
+func (l *DeepList) ExpandAll() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.setAllExpanded(true)
+	return l
+}
+
+// CollapseAll hides the sublists of all items. The "expanded" callback is
+// invoked for each sublist which was displayed before. If a sub item was
+// selected, its top-level ancestor is selected instead, triggering a "changed"
+// event.
+func (l *DeepList) CollapseAll() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.setAllExpanded(false) {
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return l
+}
+
+// setAllExpanded shows or hides the sublists of all items. It returns whether
+// the selection changed, see setTreeExpanded().
+func (l *DeepList) setAllExpanded(expanded bool) bool {
+	return l.setTreeExpanded(nil, l.items, expanded)
+}
+
+// setTreeExpanded shows or hides the sublists of the given items, whose parent
+// is at the given path (nil for top-level items), and of all their descendants.
+// When hiding sublists, a selection inside of them is moved onto the nearest
+// ancestor which remains visible. It returns whether the selection changed.
+// The "changed" callback is not called.
+func (l *DeepList) setTreeExpanded(parent []int, items []*deepListItem, expanded bool) bool {
+	var changed bool
+	for index, item := range items {
+		if item.SubList == nil {
+			continue
+		}
+		path := append(parent[:len(parent):len(parent)], index)
+		l.setExpanded(path, expanded)
+		if !expanded && l.selectAncestor(path) {
+			changed = true
+		}
+		if l.setTreeExpanded(path, item.SubList.items, expanded) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// SetExpandRecursiveKey sets the key (a printable character) which displays
+// the sublists of the selected item and of all of its descendants, like in
+// many file trees. The same key with the Alt modifier hides them all. The
+// default is '*'. The "expanded" callback is invoked for each sublist whose
+// display state changes. The key is only consumed if the selected item has sub
+// items, otherwise it is handled like any other key. Set it to 0 to disable
+// the key.
+func (l *DeepList) SetExpandRecursiveKey(key rune) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.expandRecursiveKey = key
+	return l
+}
+
+// InsertItem adds a new item to the list at the specified index. An index of 0
+// will insert the item at the beginning, an index of 1 before the second item,
+// and so on. An index of GetItemCount() or higher will insert the item at the
+// end of the list. Negative indices are also allowed: An index of -1 will
+// insert the item at the end of the list, an index of -2 before the last item,
+// and so on. An index of -GetItemCount()-1 or lower will insert the item at the
+// beginning.
+//
+// An item has a main text which will be highlighted when selected. It also has
+// a secondary text which is shown underneath the main text (if it is set to
+// visible) but which may remain empty.
+//
+// The shortcut is a key binding. If the specified rune is entered, the item
+// is selected immediately. If several items have the same shortcut, the first
+// of them is selected (see also SetShortcutConflictFunc()). Set to 0 for no
+// binding. Shortcuts are drawn in a column left of the item texts which is as
+// wide as the widest shortcut, so two-cell runes such as CJK characters or
+// emoji are supported.
+//
+// The "selected" callback will be invoked when the user selects the item. You
+// may provide nil if no such callback is needed or if all events are handled
+// through the selected callback set with SetSelectedFunc().
+//
+// The currently selected item will shift its position accordingly. If the list
+// was previously empty, a "changed" event is fired because the new item becomes
+// selected.
+func (l *DeepList) InsertItem(index int, mainText, secondaryText string, shortcut rune, selected func()) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.insertItem(index, &deepListItem{
+		MainText:      mainText,
+		SecondaryText: secondaryText,
+		Shortcut:      shortcut,
+		Selected:      selected,
+	})
+	return l
+}
+
+// insertItem inserts the given item at the given top-level index. See
+// InsertItem() for details.
+func (l *DeepList) insertItem(index int, item *deepListItem) {
+	l.saveUndo()
+
+	// Shift index to range.
+	if index < 0 {
+		index = len(l.items) + index + 1
+	}
+	if index < 0 {
+		index = 0
+	} else if index > len(l.items) {
+		index = len(l.items)
+	}
+
+	// Shift current item.
+	if l.currentItem[0] < len(l.items) && l.currentItem[0] >= index {
+		l.currentItem[0]++
+	}
+
+	// Insert item (make space for the new item, then shift and insert).
+	l.items = append(l.items, nil)
+	if index < len(l.items)-1 { // -1 because l.items has already grown by one item.
+		copy(l.items[index+1:], l.items[index:])
+	}
+	l.items[index] = item
+	l.invalidateRows()
+	if l.following {
+		l.scrollToEnd()
+	}
+	l.normalizeCurrentItem()
+	l.checkShortcutConflict(item.Shortcut)
+
+	// Fire a "change" event for the first item in the list. If the current item
+	// cannot be selected, the new item becomes selected instead.
+	if current := l.itemAt(l.currentItem); item.separator || item.header {
+		return
+	} else if len(l.items) == 1 {
+		l.fireChanged()
+	} else if current == nil || current.separator || current.header {
+		rows := l.visibleRows()
+		if index := selectableRow(rows, 0, 1, false); index >= 0 {
+			l.currentItem = append([]int(nil), rows[index].path...)
+			l.fireChanged()
+		}
+	}
+}
+
+// GetItemCount returns the number of top-level items in the list. Items hidden
+// by the filter (see SetFilter()) are included, as they keep their indices. Use
+// GetVisibleItemCount() for the number of items the filter lets through, and
+// GetSiblingCount() for the number of items in a sublist.
+func (l *DeepList) GetItemCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.items)
+}
+
+// GetItemDepth returns the depth of the item at the given path, 0 for top-level
+// items, 1 for their sub items, and so on. It returns -1 if the path does not
+// lead to an existing item.
+func (l *DeepList) GetItemDepth(path []int) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.itemAt(path) == nil {
+		return -1
+	}
+	return len(path) - 1
+}
+
+// ParentPath returns the path of the parent of the item at the given path. It
+// returns nil for top-level items and if the path does not lead to an existing
+// item.
+func (l *DeepList) ParentPath(path []int) []int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(path) < 2 || l.itemAt(path) == nil {
+		return nil
+	}
+	return append([]int(nil), path[:len(path)-1]...)
+}
+
+// GetSiblingCount returns the number of items in the list containing the item
+// at the given path, including the item itself. It returns 0 if the path does
+// not lead to an existing item.
+func (l *DeepList) GetSiblingCount(path []int) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.siblingCount(path)
+}
+
+// siblingCount implements GetSiblingCount().
+func (l *DeepList) siblingCount(path []int) int {
+	if l.itemAt(path) == nil {
+		return 0
+	}
+	if len(path) == 1 {
+		return len(l.items)
+	}
+	return len(l.itemAt(path[:len(path)-1]).SubList.items)
+}
+
+// GetItemRect returns the screen rectangle of the row of the item at the given
+// path as of the last call to Draw(), e.g. to show a popup next to the item.
+// The rectangle spans the width of the list (without the scrollbar) and all
+// lines of the item which were drawn. If the item was not drawn, for example
+// because it was scrolled out of view, is in a hidden sublist, or does not
+// exist, visible is false.
+func (l *DeepList) GetItemRect(path []int) (x, y, width, height int, visible bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, row := range l.drawnRows {
+		if equals(row.path, path) {
+			return l.drawnX, row.y, l.drawnWidth, row.height, true
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// GetVisibleItemCount returns the number of items which are currently shown,
+// i.e. the top-level items and the items of displayed sublists which match the
+// filter (if any).
+func (l *DeepList) GetVisibleItemCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.visibleRows())
+}
+
+// VisiblePaths returns the paths of the items which are drawn with the current
+// offset (see SetOffset()) and size, from top to bottom, including an item
+// whose rows are cut off at the bottom. A section header drawn as a sticky
+// header (see SetStickyHeaders()) is not included. The paths may be modified.
+func (l *DeepList) VisiblePaths() [][]int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, _, _, height := l.GetInnerRect()
+	rows := l.visibleRows()
+	if l.stickyHeader(rows) != nil {
+		height--
+	}
+	width := l.contentWidth(rows)
+	var paths [][]int
+	for index := l.itemOffset; index < len(rows) && height > 0; index++ {
+		paths = append(paths, append([]int(nil), rows[index].path...))
+		height -= l.rowHeight(rows[index], width)
+	}
+	return paths
+}
+
+// ContentHeight returns the number of rows needed to draw all visible items
+// (see GetVisibleItemCount()) without scrolling, including secondary texts and
+// wrapped lines, e.g. to size a container to fit the list. Wrapping depends on
+// the list's current width. The border and padding of the list's box are not
+// included.
+func (l *DeepList) ContentHeight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	rows := l.visibleRows()
+	return l.contentLines(rows, l.contentWidth(rows))
+}
+
+// GetVisibleRows returns the number of rows available to items, i.e. the
+// height of the list's inner area. See also GetItemsPerPage().
+func (l *DeepList) GetVisibleRows() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, _, _, height := l.GetInnerRect()
+	if height < 0 {
+		return 0
+	}
+	return height
+}
+
+// GetItemsPerPage returns the number of items which fit into the list's inner
+// area, assuming each item occupies two rows if secondary texts are shown (see
+// ShowSecondaryText()) and one row otherwise. It may be used to implement
+// custom paging. Wrapped or multi-line texts are not taken into account.
+func (l *DeepList) GetItemsPerPage() int {
+	rows := l.GetVisibleRows()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.showSecondaryText {
+		return rows / 2
+	}
+	return rows
+}
+
+// GetItemText returns the texts (main and secondary) of the top-level item with
+// the given index. Empty strings are returned if the index is out of range.
+// See also GetItemTextPath().
+func (l *DeepList) GetItemText(index int) (main, secondary string) {
+	main, secondary, _ = l.GetItemTextPath([]int{index})
+	return
+}
+
+// GetItemTextPath returns the texts (main and secondary) of the item at the
+// given path. If the path does not lead to an existing item, ok is false.
+func (l *DeepList) GetItemTextPath(path []int) (main, secondary string, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	item := l.itemAt(path)
+	if item == nil {
+		return "", "", false
+	}
+	return item.MainText, item.SecondaryText, true
+}
+
+// GetItemAt returns the properties of the item at the given path: its main and
+// secondary text, its shortcut (0 if there is none), whether it has a sublist
+// with at least one item, and whether that sublist is displayed. If the path
+// does not lead to an existing item, ok is false.
+func (l *DeepList) GetItemAt(path []int) (main, secondary string, shortcut rune, hasChildren, expanded bool, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	item := l.itemAt(path)
+	if item == nil {
+		return "", "", 0, false, false, false
+	}
+	hasChildren = item.SubList != nil && len(item.SubList.items) > 0
+	expanded = hasChildren && item.SubList.display
+	return item.MainText, item.SecondaryText, item.Shortcut, hasChildren, expanded, true
+}
+
+// SetItemText sets the main and secondary text of the top-level item with the
+// given index. Nothing happens if the index is out of range. See also
+// SetItemTextPath().
+func (l *DeepList) SetItemText(index int, main, secondary string) *DeepList {
+	return l.SetItemTextPath([]int{index}, main, secondary)
+}
+
+// SetItemTextPath sets the main and secondary text of the item at the given
+// path. Nothing happens if the path does not lead to an existing item.
+func (l *DeepList) SetItemTextPath(path []int, main, secondary string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	item := l.itemAt(path)
+	if item == nil {
+		return l
+	}
+	item.setMainText(main)
+	item.SecondaryText = secondary
+	l.invalidateRows()
+	return l
+}
+
+// SetItemSecondaryVisible sets whether the secondary text of the item at the
+// given path is shown, overriding the list-wide setting of ShowSecondaryText()
+// for this item. This also allows showing the secondary texts of sub items,
+// which are otherwise hidden. Nothing happens if the path does not lead to an
+// existing item.
+func (l *DeepList) SetItemSecondaryVisible(path []int, visible bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.secondaryVisibilitySet = true
+		item.secondaryVisible = visible
+		l.invalidateLayout()
+	}
+	return l
+}
+
+// SetItemSecondaryLines sets the secondary text of the item at the given path
+// to the given lines, joined with newline characters. Each line of a secondary
+// text is drawn on its own row. Nothing happens if the path does not lead to
+// an existing item.
+func (l *DeepList) SetItemSecondaryLines(path []int, lines []string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.SecondaryText = strings.Join(lines, "\n")
+		l.invalidateRows()
+	}
+	return l
+}
+
+// SetMouseHover sets a flag which determines whether the item under the mouse
+// cursor is highlighted with the hover style (see SetHoverStyle()), unless it
+// is the selected item. The list does not capture the mouse, so other
+// primitives receive all mouse events outside of it. As it therefore cannot
+// tell when the mouse leaves it, the highlight is only drawn once after each
+// mouse event the list receives: When the list is drawn again without another
+// mouse event in between, e.g. because the mouse moved onto another primitive,
+// the highlight is removed until the mouse moves over the list again.
+func (l *DeepList) SetMouseHover(hover bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.mouseHover = hover
+	if !hover {
+		l.hoverItem = nil
+	}
+	return l
+}
+
+// SetHoverStyle sets the style of the item under the mouse cursor when mouse
+// hover is enabled with SetMouseHover(). Only the background of the style is
+// applied to the item's texts.
+func (l *DeepList) SetHoverStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.hoverStyle = style
+	return l
+}
+
+// SetMultiSelectMode sets a flag which determines whether more than one item
+// can be selected with the mouse or the keyboard, like in the file managers of
+// desktop operating systems: A click selects only the clicked item, a click
+// with the Shift key held down selects all selectable items drawn between the
+// item clicked last (the anchor) and the clicked item, and a click with the
+// Ctrl key held down adds the clicked item to the selection or removes it from
+// it. Likewise, Shift+Down and Shift+Up move the current item and select all
+// items between the anchor and the new current item. All selected items are
+// drawn in the selected style. Moving the selection with the keyboard otherwise
+// selects only the newly selected item. See GetSelectedPaths() for the selected
+// items and SetSelectionChangedFunc() to be notified of changes.
+//
+// In either case, the clicked item also becomes the current item (see
+// GetCurrentItem()), which the keyboard navigation starts from. Note that not
+// all terminals report clicks with the Shift or Ctrl key held down.
+func (l *DeepList) SetMultiSelectMode(multiSelect bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.multiSelect = multiSelect
+	if multiSelect {
+		l.collapseSelection()
+		l.fireSelectionChanged()
+	} else {
+		l.multiSelection, l.anchorItem, l.reportedSelection = nil, nil, nil
+	}
+	return l
+}
+
+// SetSelectionChangedFunc sets the function which is called with the paths of
+// the selected items (see GetSelectedPaths()) whenever the set of selected
+// items changes in multi-select mode (see SetMultiSelectMode()), e.g. when the
+// user extends the selection with a Shift-click or Shift+Up/Down.
+func (l *DeepList) SetSelectionChangedFunc(handler func(paths [][]int)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.selectionChanged = handler
+	return l
+}
+
+// GetSelectedPaths returns the paths of the selected items in the order in
+// which they appear in the item tree. Unless multi-select mode is enabled (see
+// SetMultiSelectMode()), this is only the current item. The result is empty if
+// the list has no items.
+func (l *DeepList) GetSelectedPaths() [][]int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.multiSelect {
+		if l.itemAt(l.currentItem) == nil {
+			return nil
+		}
+		return [][]int{append([]int(nil), l.currentItem...)}
+	}
+	return l.selectedPaths()
+}
+
+// selectedPaths returns the paths of the items in the multi-selection in the
+// order in which they appear in the item tree.
+func (l *DeepList) selectedPaths() [][]int {
+	var (
+		paths [][]int
+		walk  func(path []int, items []*deepListItem)
+	)
+	walk = func(path []int, items []*deepListItem) {
+		for index, item := range items {
+			itemPath := append(append([]int(nil), path...), index)
+			if l.multiSelection[item] {
+				paths = append(paths, itemPath)
+			}
+			if item.SubList != nil {
+				walk(itemPath, item.SubList.items)
+			}
+		}
+	}
+	walk(nil, l.items)
+	return paths
+}
+
+// fireSelectionChanged invokes the "selection changed" callback if the
+// multi-selection differs from the one last reported.
+func (l *DeepList) fireSelectionChanged() {
+	if !l.multiSelect {
+		return
+	}
+	changed := len(l.multiSelection) != len(l.reportedSelection)
+	for item := range l.multiSelection {
+		if !l.reportedSelection[item] {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+	l.reportedSelection = make(map[*deepListItem]bool, len(l.multiSelection))
+	for item := range l.multiSelection {
+		l.reportedSelection[item] = true
+	}
+	if l.selectionChanged != nil {
+		paths := l.selectedPaths()
+		handler := l.selectionChanged
+		l.unlocked(func() { handler(paths) })
+	}
+}
+
+// extendSelection moves the current item by one selectable item in the given
+// direction (1 = down, -1 = up) without wrapping around and replaces the
+// multi-selection with the items from the anchor to the new current item. The
+// given path is the current item before the move.
+func (l *DeepList) extendSelection(direction int, previous []int) {
+	l.moveSelection(direction, false)
+	l.selectRange(l.currentItem)
+	l.extendingSelection = !equals(l.currentItem, previous)
+}
+
+// collapseSelection reduces the multi-selection to the current item, which
+// also becomes the anchor of range selections.
+func (l *DeepList) collapseSelection() {
+	l.multiSelection = make(map[*deepListItem]bool)
+	l.anchorItem = l.itemAt(l.currentItem)
+	if l.anchorItem != nil {
+		l.multiSelection[l.anchorItem] = true
+	}
+}
+
+// clickSelection updates the multi-selection for a click with the given
+// modifier keys on the item at the given path. The current item is not
+// changed but the next "changed" event keeps the new selection.
+func (l *DeepList) clickSelection(path []int, modifiers tcell.ModMask) {
+	item := l.itemAt(path)
+	switch {
+	case modifiers&tcell.ModShift != 0:
+		l.selectRange(path)
+	case modifiers&tcell.ModCtrl != 0:
+		if l.multiSelection[item] {
+			delete(l.multiSelection, item)
+		} else {
+			l.multiSelection[item] = true
+		}
+		l.anchorItem = item
+	default:
+		l.multiSelection = map[*deepListItem]bool{item: true}
+		l.anchorItem = item
+	}
+	l.extendingSelection = !equals(path, l.currentItem)
+}
+
+// selectRange replaces the multi-selection with the selectable items drawn
+// between the anchor item (or the current item if the anchor is not drawn) and
+// the item at the given path, both included.
+func (l *DeepList) selectRange(path []int) {
+	rows := l.visibleRows()
+	from, to := rowIndex(rows, path), -1
+	for index, row := range rows {
+		if row.item == l.anchorItem {
+			to = index
+			break
+		}
+	}
+	if to < 0 {
+		to = l.currentRowIndex(rows)
+	}
+	if from < 0 || to < 0 {
+		return
+	}
+	if from > to {
+		from, to = to, from
+	}
+	l.multiSelection = make(map[*deepListItem]bool)
+	for _, row := range rows[from : to+1] {
+		if selectable(row) {
+			l.multiSelection[row.item] = true
+		}
+	}
+}
+
+// SetSelectAnimation sets a flag which determines whether an item which is
+// selected (with the Enter key, its shortcut, or a double click) briefly
+// flashes in the select animation style (see SetSelectAnimationStyle()) instead
+// of the selected style. The flash lasts for the next two calls to Draw().
+//
+// The application redraws the list after the key or mouse event that selected
+// the item but not afterwards, so the flash only ends when the screen is drawn
+// again. To animate it smoothly, redraw periodically, for example:
+//
+//	go func() {
+//	  for range time.Tick(100 * time.Millisecond) {
+//	    app.Draw()
+//	  }
+//	}()
+func (l *DeepList) SetSelectAnimation(animate bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.selectAnimation = animate
+	return l
+}
+
+// SetSelectAnimationStyle sets the style of the flash shown when an item is
+// selected and the select animation is enabled with SetSelectAnimation(). Only
+// the background of the style is applied to the item's texts.
+func (l *DeepList) SetSelectAnimationStyle(style tcell.Style) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.animationStyle = style
+	return l
+}
+
+// selectItem calls the "selected" callbacks of the item at the given path, the
+// item's own and the list's, and starts the select animation. Nothing happens
+// if the path does not lead to an existing item. The callbacks may modify the
+// list, so callers must not rely on any state read before calling this.
+func (l *DeepList) selectItem(path []int) {
+	item := l.itemAt(path)
+	if item == nil {
+		return
+	}
+	path = append([]int(nil), path...)
+	l.animateSelection(path)
+	if item.Selected != nil {
+		l.unlocked(item.Selected)
+	}
+	if l.selected != nil {
+		handler := l.selected
+		mainText, secondaryText, shortcut := item.MainText, item.SecondaryText, item.Shortcut
+		l.unlocked(func() { handler(path, mainText, secondaryText, shortcut) })
+	}
+}
+
+// animateSelection starts the select animation for the item at the given path
+// if the animation is enabled.
+func (l *DeepList) animateSelection(path []int) {
+	if l.selectAnimation {
+		l.animatedItem = append([]int(nil), path...)
+		l.animationFrames = selectAnimationFrames
+	}
+}
+
+// PathString returns a string representation of the given DeepList item path
+// with the indices separated by slashes, e.g. "0/2/1" for []int{0, 2, 1}, which
+// is suitable for logging or persisting a selection. Use ParsePath() to convert
+// it back.
+func PathString(path []int) string {
+	indices := make([]string, len(path))
+	for depth, index := range path {
+		indices[depth] = strconv.Itoa(index)
+	}
+	return strings.Join(indices, "/")
+}
+
+// ParsePath parses a DeepList item path in the format returned by PathString(),
+// e.g. "0/2/1". Negative indices (which refer to items from the back, see
+// DeepList.SetCurrentItem()) are accepted. An error is returned if the string
+// is empty or if any of its slash-separated parts is not a decimal integer.
+func ParsePath(s string) ([]int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	parts := strings.Split(s, "/")
+	path := make([]int, len(parts))
+	for depth, part := range parts {
+		digits := strings.TrimPrefix(part, "-")
+		if digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return nil, fmt.Errorf("invalid index %q in path %q", part, s)
+		}
+		index, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q in path %q: %w", part, s, err)
+		}
+		path[depth] = index
+	}
+	return path, nil
+}
+
+// ValidatePath resolves the given path against the current items, e.g. to check
+// a persisted selection against a rebuilt tree. At each level, negative indices
+// refer to items from the back (-1 = last item, -2 = second-to-last item, and so
+// on, resolved before clamping) and out of range indices are clamped to the
+// first/last item. The path is truncated at items without sub items. An empty
+// path, or any path into an empty list, is clamped to []int{0}. The returned
+// flag is true if no index had to be clamped and the path was not truncated,
+// i.e. if the path (with negative indices resolved) leads to an existing item.
+func (l *DeepList) ValidatePath(path []int) (clamped []int, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return parseIndexes(path, l.items)
+}
+
+// SelectByShortcut selects the first visible top-level item with the given
+// shortcut, as if its shortcut key was pressed, but without calling any
+// "selected" callbacks. The "changed" callback is called if the selection
+// changes. It returns whether such an item was found.
+func (l *DeepList) SelectByShortcut(shortcut rune) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	rows := l.visibleRows()
+	index := shortcutRow(rows, shortcut)
+	if index < 0 {
+		return false
+	}
+	if path := rows[index].path; !equals(path, l.currentItem) {
+		l.currentItem = append([]int(nil), path...)
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return true
+}
+
+// SelectByText selects the first item whose main text equals the given text
+// or, if exact is false, contains it. Items in hidden sublists are considered,
+// too: The sublists of their ancestors are displayed to reveal them.
+// Separators, section headers, and items excluded by the filter (see
+// SetFilter()) are skipped. If the matching item is hidden by the maximum
+// visible depth (see SetMaxVisibleDepth()), its visible ancestor is selected
+// instead. The "changed" callback is called if the selection changes. It
+// returns whether a matching item was found.
+func (l *DeepList) SelectByText(mainText string, exact bool) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var found []int
+	var walk func(parent []int, items []*deepListItem) bool
+	walk = func(parent []int, items []*deepListItem) bool {
+		for index, item := range items {
+			path := append(parent[:len(parent):len(parent)], index)
+			if !item.separator && !item.header &&
+				(l.filter == nil || l.filter(path, item.MainText, item.SecondaryText, item.Shortcut)) &&
+				(item.MainText == mainText || !exact && strings.Contains(item.MainText, mainText)) {
+				found = path
+				return true
+			}
+			if item.SubList != nil && walk(path, item.SubList.items) {
+				return true
+			}
+		}
+		return false
+	}
+	if !walk(nil, l.items) {
+		return false
+	}
+	if !l.aboveMaxDepth(found[:len(found)-1]) {
+		// Hidden by the maximum visible depth. Select the visible ancestor.
+		found = found[:l.maxVisibleDepth]
+	}
+
+	for depth := 1; depth < len(found); depth++ {
+		l.setExpanded(found[:depth], true)
+	}
+	if !equals(found, l.currentItem) {
+		l.currentItem = found
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return true
+}
+
+// SetItemShortcut sets the shortcut of the item at the given path. Set to 0 to
+// remove the shortcut. Nothing happens if the path does not lead to an existing
+// item.
+func (l *DeepList) SetItemShortcut(path []int, shortcut rune) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.Shortcut = shortcut
+		l.invalidateRows()
+		if len(path) == 1 {
+			l.checkShortcutConflict(shortcut)
+		}
+	}
+	return l
+}
+
+// HasShortcutConflict returns the first shortcut (in the order of the items)
+// which is shared by more than one top-level item and true, or 0 and false if
+// all shortcuts are unique. Only top-level items are considered because the
+// shortcuts of sub items are not used. Separators and section headers are
+// ignored. Of several items with the same shortcut, typing it selects the
+// first one.
+func (l *DeepList) HasShortcutConflict() (rune, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	seen := make(map[rune]bool)
+	for _, item := range l.items {
+		if item.Shortcut == 0 || item.separator || item.header {
+			continue
+		}
+		if seen[item.Shortcut] {
+			return item.Shortcut, true
+		}
+		seen[item.Shortcut] = true
+	}
+	return 0, false
+}
+
+// AutoAssignShortcuts gives each top-level item without a shortcut the first
+// letter of its main text (ignoring color tags) which is not yet used as a
+// shortcut. Letters are assigned in lower case and compared case-insensitively,
+// so an item with the shortcut "A" also blocks "a". If none of an item's
+// letters is available, it receives the first unused digit from "1" to "9"
+// and "0". If no digit is left either, the item remains without a shortcut.
+// Items are processed from top to bottom. Separators, section headers, and
+// items with a string shortcut (see SetItemStringShortcut()) are skipped, as
+// are the Vim keys if enabled with SetVimKeys().
+func (l *DeepList) AutoAssignShortcuts() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	used := make(map[rune]bool)
+	if l.vimKeys {
+		for _, r := range "jkhlg" {
+			used[r] = true
+		}
+	}
+	for _, item := range l.items {
+		if item.Shortcut != 0 {
+			used[unicode.ToLower(item.Shortcut)] = true
+		}
+	}
+
+	for _, item := range l.items {
+		if item.Shortcut != 0 || item.stringShortcut != "" || item.separator || item.header {
+			continue
+		}
+		for _, r := range stripTags(item.MainText) {
+			if r = unicode.ToLower(r); unicode.IsLetter(r) && !used[r] {
+				item.Shortcut = r
+				break
+			}
+		}
+		if item.Shortcut == 0 {
+			for _, r := range "1234567890" {
+				if !used[r] {
+					item.Shortcut = r
+					break
+				}
+			}
+		}
+		used[item.Shortcut] = true
+	}
+
+	l.invalidateRows()
+	return l
+}
+
+// SetShortcutConflictFunc sets a function which is called when a top-level
+// item is inserted, or its shortcut is changed with SetItemShortcut(), and its
+// shortcut is already used by another top-level item. The function receives
+// the shortcut and the paths of all top-level items which have it. The list
+// is not changed, it is up to the function to resolve the conflict.
+func (l *DeepList) SetShortcutConflictFunc(handler func(shortcut rune, paths [][]int)) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.shortcutConflict = handler
 	return l
 }
 
-// SetSelectedFocusOnly sets a flag which determines when the currently selected
-// list item is highlighted. If set to true, selected items are only highlighted
-// when the list has focus. If set to false, they are always highlighted.
-func (l *DeepList) SetSelectedFocusOnly(focusOnly bool) *DeepList {
-	l.selectedFocusOnly = focusOnly
-	return l
+// checkShortcutConflict calls the shortcut conflict handler if more than one
+// top-level item has the given shortcut.
+func (l *DeepList) checkShortcutConflict(shortcut rune) {
+	if l.shortcutConflict == nil || shortcut == 0 {
+		return
+	}
+	var paths [][]int
+	for index, item := range l.items {
+		if item.Shortcut == shortcut && !item.separator && !item.header {
+			paths = append(paths, []int{index})
+		}
+	}
+	if len(paths) > 1 {
+		handler := l.shortcutConflict
+		l.unlocked(func() { handler(shortcut, paths) })
+	}
 }
 
-// SetHighlightFullLine sets a flag which determines whether the colored
-// background of selected items spans the entire width of the view. If set to
-// true, the highlight spans the entire view. If set to false, only the text of
-// the selected item from beginning to end is highlighted.
-func (l *DeepList) SetHighlightFullLine(highlight bool) *DeepList {
-	l.highlightFullLine = highlight
+// SetItemStringShortcut sets a shortcut consisting of a sequence of keys, e.g.
+// "gg", for the item at the given path. Typing the keys of the sequence, with
+// no more than a second between them, selects the item like a single-rune
+// shortcut. As only the shortcuts of top-level items are drawn and matched,
+// string shortcuts of sub items have no effect. A string shortcut is drawn in
+// parentheses instead of the item's rune shortcut, regardless of
+// SetShortcutFormat(). An empty string removes the string shortcut.
+//
+// If a single-rune shortcut of another item is the first rune of a string
+// shortcut, the single-rune shortcut takes precedence. Nothing happens if the
+// path does not lead to an existing item.
+func (l *DeepList) SetItemStringShortcut(path []int, shortcut string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		item.stringShortcut = shortcut
+		l.invalidateLayout()
+	}
 	return l
 }
 
-// ShowSecondaryText determines whether or not to show secondary item texts.
-func (l *DeepList) ShowSecondaryText(show bool) *DeepList {
-	l.showSecondaryText = show
-	return l
+// GetItemStringShortcut returns the string shortcut of the item at the given
+// path, as set with SetItemStringShortcut(), or an empty string if the item has
+// no string shortcut or the path does not lead to an existing item.
+func (l *DeepList) GetItemStringShortcut(path []int) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		return item.stringShortcut
+	}
+	return ""
 }
 
-// SetWrapAround sets the flag that determines whether navigating the list will
-// wrap around. That is, navigating downwards on the last item will move the
-// selection to the first item (similarly in the other direction). If set to
-// false, the selection won't change when navigating downwards on the last item
-// or navigating upwards on the first item.
-func (l *DeepList) SetWrapAround(wrapAround bool) *DeepList {
-	l.wrapAround = wrapAround
-	return l
+// GetItemShortcut returns the shortcut of the item at the given path, or 0 if
+// the item has no shortcut or the path does not lead to an existing item.
+func (l *DeepList) GetItemShortcut(path []int) rune {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if item := l.itemAt(path); item != nil {
+		return item.Shortcut
+	}
+	return 0
 }
 
-// SetChangedFunc sets the function which is called when the user navigates to
-// a list item. The function receives the item's index in the list of items
-// (starting with 0), its main text, secondary text, and its shortcut rune.
+// FindItems searches the main and secondary texts for the given strings and
+// returns a list of item indices in which those strings are found. One of the
+// two search strings may be empty, it will then be ignored. Indices are always
+// returned in ascending order.
 //
-// This function is also called when the first item is added or when
-// SetCurrentItem() is called.
-func (l *DeepList) SetChangedFunc(handler func(indexes []int, mainText string, secondaryText string, shortcut rune)) *DeepList {
-	l.changed = handler
-	return l
+// If mustContainBoth is set to true, mainSearch must be contained in the main
+// text AND secondarySearch must be contained in the secondary text. If it is
+// false, only one of the two search strings must be contained.
+//
+// Set ignoreCase to true for case-insensitive search.
+func (l *DeepList) FindItems(mainSearch, secondarySearch string, mustContainBoth, ignoreCase bool) (indices []int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if mainSearch == "" && secondarySearch == "" {
+		return
+	}
+
+	if ignoreCase {
+		mainSearch = strings.ToLower(mainSearch)
+		secondarySearch = strings.ToLower(secondarySearch)
+	}
+
+	for index, item := range l.items {
+		mainText := item.MainText
+		secondaryText := item.SecondaryText
+		if ignoreCase {
+			mainText = strings.ToLower(mainText)
+			secondaryText = strings.ToLower(secondaryText)
+		}
+
+		// strings.Contains() always returns true for a "" search.
+		mainContained := strings.Contains(mainText, mainSearch)
+		secondaryContained := strings.Contains(secondaryText, secondarySearch)
+		if mustContainBoth && mainContained && secondaryContained ||
+			!mustContainBoth && (mainText != "" && mainContained || secondaryText != "" && secondaryContained) {
+			indices = append(indices, index)
+		}
+	}
+
+	return
 }
 
-// SetSelectedFunc sets the function which is called when the user selects a
-// list item by pressing Enter on the current selection. The function receives
-// the item's index in the list of items (starting with 0), its main text,
-// secondary text, and its shortcut rune.
-func (l *DeepList) SetSelectedFunc(handler func([]int, string, string, rune)) *DeepList {
-	l.selected = handler
+// Clear removes all items from the list.
+func (l *DeepList) Clear() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.saveUndo()
+	l.items = nil
+	l.invalidateRows()
+	l.currentItem = []int{0}
+	l.reportedItem = []int{0}
 	return l
 }
 
-// SetDoneFunc sets a function which is called when the user presses the Escape
-// key.
-func (l *DeepList) SetDoneFunc(handler func()) *DeepList {
-	l.done = handler
+// EnableHistory enables undoing and redoing structural changes to the item
+// tree with Undo() and Redo(). Before an item is inserted (including sub items,
+// separators, and section headers), removed, or all items are cleared or
+// replaced with UnmarshalTree(), a copy of the whole item tree is saved. At
+// most the given number of copies are kept, the oldest ones are discarded. As
+// each copy holds all items, memory use grows with both the limit and the size
+// of the tree, so keep the limit small for large lists. A limit of 0 (the
+// default) disables the history.
+//
+// Other changes, e.g. to item texts or to the display of sublists, are not
+// recorded themselves but are undone along with the next structural change
+// recorded after them.
+//
+// Calling this function discards any previously saved copies.
+func (l *DeepList) EnableHistory(limit int) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.historyLimit = limit
+	l.undoStack, l.nextUndo = nil, 0
 	return l
 }
 
-// AddItem calls InsertItem() with an index of -1.
-func (l *DeepList) AddItem(mainText, secondaryText string, shortcut rune, selected func()) *DeepList {
-	l.InsertItem(-1, mainText, secondaryText, shortcut, selected)
-	return l
+// Undo restores the item tree and the selection to their state before the last
+// structural change, see EnableHistory(). It returns false if there is nothing
+// to undo. A "changed" event is fired if the selection changes.
+func (l *DeepList) Undo() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.nextUndo == 0 {
+		return false
+	}
+	l.nextUndo--
+	l.swapUndo(l.nextUndo)
+	return true
 }
 
-func (l *DeepList) AddSubItem(mainText, secondaryText string, shortcut rune, display bool, selected func()) *DeepList {
-	lastIndex := len(l.items) - 1
-	if lastIndex < 0 {
-		return l
+// Redo reverts the last Undo(). It returns false if there is nothing to redo.
+// Any structural change after an undo discards the changes which could be
+// redone. A "changed" event is fired if the selection changes.
+func (l *DeepList) Redo() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.nextUndo >= len(l.undoStack) {
+		return false
+	}
+	l.swapUndo(l.nextUndo)
+	l.nextUndo++
+	return true
+}
+
+// saveUndo saves a copy of the item tree on the undo stack if the history is
+// enabled, discarding all changes which could be redone.
+func (l *DeepList) saveUndo() {
+	if l.historyLimit <= 0 {
+		return
 	}
+	l.undoStack = append(l.undoStack[:l.nextUndo], deepListUndoItem{
+		items:       copyItems(l.items),
+		currentItem: append([]int(nil), l.currentItem...),
+	})
+	if len(l.undoStack) > l.historyLimit {
+		l.undoStack = append(l.undoStack[:0], l.undoStack[len(l.undoStack)-l.historyLimit:]...)
+	}
+	l.nextUndo = len(l.undoStack)
+}
 
-	item := &deepListItem{
-		MainText:      mainText,
-		SecondaryText: secondaryText,
-		Shortcut:      shortcut,
-		Selected:      selected,
+// swapUndo replaces the list's item tree and selection with the state saved at
+// the given position of the undo stack, which then holds the replaced state.
+func (l *DeepList) swapUndo(index int) {
+	state := l.undoStack[index]
+	l.undoStack[index] = deepListUndoItem{items: l.items, currentItem: l.currentItem}
+	previous := l.currentItem
+	l.items = state.items
+	l.currentItem = state.currentItem
+	l.invalidateRows()
+	l.normalizeCurrentItem()
+	if l.multiSelect {
+		l.collapseSelection()
+		l.fireSelectionChanged()
 	}
+	if len(l.items) > 0 && !equals(l.currentItem, previous) {
+		l.fireChanged()
+	}
+	l.adjustOffset()
+}
 
-	parentItem := l.items[lastIndex]
-	if parentItem.SubList == nil {
-		parentItem.SubList = &subList{
+// Clone returns a copy of the list which shares no mutable state with it: The
+// item tree (including the display state of sublists), the selection, the
+// styles, the settings, and the embedded Box (position, border, title, and so
+// on) are copied. Changes to either list don't affect the other.
+//
+// Functions and item references (see SetItemReference()) are copied by
+// reference. This includes the items' "selected" callbacks and all handlers set
+// on the list, which therefore continue to refer to whatever they referred to
+// before, e.g. the original list. The clone does not have focus. Its undo
+// history (see EnableHistory()) is empty.
+func (l *DeepList) Clone() *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	clone := *l
+	clone.mutex = &sync.Mutex{}
+	box := *l.Box
+	box.hasFocus = false
+	clone.Box = &box
+
+	clone.items = copyItems(l.items)
+	clone.invalidateRows()
+	clone.undoStack, clone.nextUndo = nil, 0
+
+	clone.currentItem = append([]int(nil), l.currentItem...)
+	clone.reportedItem = append([]int(nil), l.reportedItem...)
+	clone.animatedItem = append([]int(nil), l.animatedItem...)
+	clone.hoverItem = nil
+	if l.multiSelect {
+		clone.collapseSelection()
+		clone.reportedSelection = make(map[*deepListItem]bool)
+		for item := range clone.multiSelection {
+			clone.reportedSelection[item] = true
+		}
+	}
+	clone.drawnRows = nil
+	clone.scrollbarDragging = false
+	clone.columns = append([]int(nil), l.columns...)
+	if l.depthStyles != nil {
+		clone.depthStyles = make(map[int]deepListDepthStyle, len(l.depthStyles))
+		for depth, style := range l.depthStyles {
+			clone.depthStyles[depth] = style
+		}
+	}
+
+	return &clone
+}
+
+// deepListItemJSON is the serialized form of a deepListItem, see
+// DeepList.MarshalTree().
+type deepListItemJSON struct {
+	MainText      string              `json:"mainText"`
+	SecondaryText string              `json:"secondaryText,omitempty"`
+	Shortcut      string              `json:"shortcut,omitempty"`
+	Keys          string              `json:"keys,omitempty"`
+	Separator     bool                `json:"separator,omitempty"`
+	Header        bool                `json:"header,omitempty"`
+	Prefix        string              `json:"prefix,omitempty"`
+	Icon          string              `json:"icon,omitempty"`
+	TrailingText  string              `json:"trailingText,omitempty"`
+	Help          string              `json:"help,omitempty"`
+	Display       bool                `json:"display,omitempty"`
+	Items         []*deepListItemJSON `json:"items,omitempty"`
+}
 
-			display: display,
-			items:   make([]*deepListItem, 1),
+// marshalItems converts the given items and their sublists to their
+// serializable form.
+func marshalItems(items []*deepListItem) []*deepListItemJSON {
+	result := make([]*deepListItemJSON, 0, len(items))
+	for _, item := range items {
+		j := &deepListItemJSON{
+			MainText:      item.MainText,
+			SecondaryText: item.SecondaryText,
+			Keys:          item.stringShortcut,
+			Separator:     item.separator,
+			Header:        item.header,
+			Prefix:        item.prefix,
+			TrailingText:  item.trailingText,
+			Help:          item.help,
 		}
-		parentItem.SubList.items[0] = item
-	} else {
-		parentItem.SubList.display = display
-		parentItem.SubList.items = append(parentItem.SubList.items, item)
+		if item.Shortcut != 0 {
+			j.Shortcut = string(item.Shortcut)
+		}
+		if item.icon != 0 {
+			j.Icon = string(item.icon)
+		}
+		if item.SubList != nil {
+			j.Display = item.SubList.display
+			if len(item.SubList.items) > 0 {
+				j.Items = marshalItems(item.SubList.items)
+			}
+		}
+		result = append(result, j)
+	}
+	return result
+}
+
+// unmarshalItems converts serialized items back into list items.
+func unmarshalItems(items []*deepListItemJSON) []*deepListItem {
+	result := make([]*deepListItem, 0, len(items))
+	for _, j := range items {
+		if j == nil {
+			continue
+		}
+		item := &deepListItem{
+			MainText:       j.MainText,
+			SecondaryText:  j.SecondaryText,
+			stringShortcut: j.Keys,
+			separator:      j.Separator,
+			header:         j.Header,
+			prefix:         j.Prefix,
+			trailingText:   j.TrailingText,
+			help:           j.Help,
+		}
+		if shortcut := []rune(j.Shortcut); len(shortcut) > 0 {
+			item.Shortcut = shortcut[0]
+		}
+		if icon := []rune(j.Icon); len(icon) > 0 {
+			item.icon = icon[0]
+		}
+		if len(j.Items) > 0 || j.Display {
+			item.SubList = &subList{
+				display: j.Display,
+				items:   unmarshalItems(j.Items),
+			}
+		}
+		result = append(result, item)
 	}
+	return result
+}
 
-	return l
+// MarshalTree serializes the list's item tree to JSON. For each item, this
+// includes its main text, secondary text, rune and string shortcuts, whether it
+// is a separator or a section header, its prefix, icon, trailing text, and help
+// text, whether its sublist is displayed, and its sub items.
+//
+// The items' "selected" callbacks and references cannot be serialized and are
+// dropped. So are the icon and trailing text styles, per-item secondary text
+// visibility (see SetItemSecondaryVisible()), horizontal offsets, and
+// loading states.
+func (l *DeepList) MarshalTree() ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return json.Marshal(marshalItems(l.items))
 }
 
-func (l *DeepList) ToggleSubListDisplay(index int) *DeepList {
-	if index >= len(l.items) {
-		return l
+// UnmarshalTree replaces the list's items with the item tree contained in the
+// given JSON data, as produced by MarshalTree(). Since "selected" callbacks are
+// not serialized, the restored items have none. Like Clear(), this is a
+// structural change which can be undone, see EnableHistory(). The selection is
+// reset to the first item, the scroll offsets are reset, and a "changed" event
+// is fired.
+//
+// If the data cannot be parsed, an error is returned and the list remains
+// unchanged.
+func (l *DeepList) UnmarshalTree(data []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var items []*deepListItemJSON
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
 	}
-	if l.items[index].SubList == nil {
-		return l
+	l.saveUndo()
+	l.items = unmarshalItems(items)
+	l.invalidateRows()
+	l.currentItem = []int{0}
+	if rows := l.visibleRows(); len(rows) > 0 {
+		if index := selectableRow(rows, 0, 1, false); index >= 0 {
+			l.currentItem = append([]int(nil), rows[index].path...)
+		}
 	}
+	l.reportedItem = []int{0}
+	l.itemOffset, l.horizontalOffset = 0, 0
+	l.multiSelection, l.anchorItem, l.extendingSelection = nil, nil, false
+	l.fireChanged()
+	return nil
+}
 
-	l.items[index].SubList.display = !l.items[index].SubList.display
+// SetExpansionKeyFunc sets the function which determines the keys used by
+// GetExpansionState() and ApplyExpansionState(). The function receives the main
+// texts of an item's ancestors (starting at the top level) followed by the
+// item's own main text. If no such function is set (or nil is provided), these
+// main texts are joined with "/".
+func (l *DeepList) SetExpansionKeyFunc(handler func(mainTexts []string) string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 
+	l.expansionKey = handler
 	return l
 }
 
-// InsertItem adds a new item to the list at the specified index. An index of 0
-// will insert the item at the beginning, an index of 1 before the second item,
-// and so on. An index of GetItemCount() or higher will insert the item at the
-// end of the list. Negative indices are also allowed: An index of -1 will
-// insert the item at the end of the list, an index of -2 before the last item,
-// and so on. An index of -GetItemCount()-1 or lower will insert the item at the
-// beginning.
-//
-// An item has a main text which will be highlighted when selected. It also has
-// a secondary text which is shown underneath the main text (if it is set to
-// visible) but which may remain empty.
-//
-// The shortcut is a key binding. If the specified rune is entered, the item
-// is selected immediately. Set to 0 for no binding.
-//
-// The "selected" callback will be invoked when the user selects the item. You
-// may provide nil if no such callback is needed or if all events are handled
-// through the selected callback set with SetSelectedFunc().
-//
-// The currently selected item will shift its position accordingly. If the list
-// was previously empty, a "changed" event is fired because the new item becomes
-// selected.
-func (l *DeepList) InsertItem(index int, mainText, secondaryText string, shortcut rune, selected func()) *DeepList {
-	item := &deepListItem{
-		MainText:      mainText,
-		SecondaryText: secondaryText,
-		Shortcut:      shortcut,
-		Selected:      selected,
+// getExpansionKey returns the expansion state key for the given main text
+// path.
+func (l *DeepList) getExpansionKey(mainTexts []string) string {
+	if l.expansionKey != nil {
+		return l.expansionKey(mainTexts)
 	}
+	return strings.Join(mainTexts, "/")
+}
 
-	// Shift index to range.
-	if index < 0 {
-		index = len(l.items) + index + 1
+// SetItemKeyFunc sets the function which determines the keys used by
+// GetSelectedKey() and SelectByKey() to identify an item across a rebuild of
+// the list. The function receives the item's path, texts, and shortcut. If no
+// such function is set (or nil is provided), an item's key is its expansion
+// state key, see SetExpansionKeyFunc().
+func (l *DeepList) SetItemKeyFunc(handler func(path []int, mainText, secondaryText string, shortcut rune) string) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.itemKey = handler
+	return l
+}
+
+// getItemKey returns the key of the given item with the given path and main
+// text path, see SetItemKeyFunc().
+func (l *DeepList) getItemKey(path []int, mainTexts []string, item *deepListItem) string {
+	if l.itemKey != nil {
+		return l.itemKey(path, item.MainText, item.SecondaryText, item.Shortcut)
 	}
-	if index < 0 {
-		index = 0
-	} else if index > len(l.items) {
-		index = len(l.items)
+	return l.getExpansionKey(mainTexts)
+}
+
+// GetSelectedKey returns the key of the selected item (see SetItemKeyFunc()),
+// e.g. to restore the selection with SelectByKey() after the list was cleared
+// and rebuilt. It returns an empty string if the list has no items.
+func (l *DeepList) GetSelectedKey() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	item := l.itemAt(l.currentItem)
+	if item == nil {
+		return ""
 	}
+	return l.getItemKey(append([]int(nil), l.currentItem...), l.pathTexts(l.currentItem), item)
+}
 
-	// Shift current item.
-	if l.currentItem[0] < len(l.items) && l.currentItem[0] >= index {
-		l.currentItem[0]++
+// pathTexts returns the main texts of the items along the given path, from the
+// top-level item to the item at the path. The path must lead to an existing
+// item.
+func (l *DeepList) pathTexts(path []int) []string {
+	mainTexts := make([]string, 0, len(path))
+	items := l.items
+	for _, index := range path {
+		item := items[index]
+		mainTexts = append(mainTexts, item.MainText)
+		if item.SubList != nil {
+			items = item.SubList.items
+		}
 	}
+	return mainTexts
+}
 
-	// Insert item (make space for the new item, then shift and insert).
-	l.items = append(l.items, nil)
-	if index < len(l.items)-1 { // -1 because l.items has already grown by one item.
-		copy(l.items[index+1:], l.items[index:])
+// SelectByKey selects the first item (in the order in which items are drawn)
+// whose key (see SetItemKeyFunc()) is the given key. Items in hidden sublists
+// are considered, too: The sublists of their ancestors are displayed to reveal
+// them. If the item is hidden by the maximum visible depth (see
+// SetMaxVisibleDepth()), its visible ancestor is selected. If there is no such
+// item, the first item which can be selected is selected instead and false is
+// returned. The "changed" callback is called if the selection changes.
+func (l *DeepList) SelectByKey(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var found []int
+	var walk func(parent []int, mainTexts []string, items []*deepListItem) bool
+	walk = func(parent []int, mainTexts []string, items []*deepListItem) bool {
+		for index, item := range items {
+			path := append(parent[:len(parent):len(parent)], index)
+			texts := append(mainTexts[:len(mainTexts):len(mainTexts)], item.MainText)
+			if !item.separator && !item.header && l.getItemKey(path, texts, item) == key {
+				found = path
+				return true
+			}
+			if item.SubList != nil && walk(path, texts, item.SubList.items) {
+				return true
+			}
+		}
+		return false
 	}
-	l.items[index] = item
+	ok := walk(nil, nil, l.items)
 
-	// Fire a "change" event for the first item in the list.
-	if len(l.items) == 1 && l.changed != nil {
-		item := l.items[0]
-		l.changed([]int{0}, item.MainText, item.SecondaryText, item.Shortcut)
+	if ok {
+		if !l.aboveMaxDepth(found[:len(found)-1]) {
+			// Hidden by the maximum visible depth. Select the visible ancestor.
+			found = found[:l.maxVisibleDepth]
+		}
+		for depth := 1; depth < len(found); depth++ {
+			l.setExpanded(found[:depth], true)
+		}
+	} else {
+		rows := l.visibleRows()
+		index := selectableRow(rows, 0, 1, false)
+		if index < 0 {
+			return false
+		}
+		found = append([]int(nil), rows[index].path...)
 	}
-	return l
+	if !equals(found, l.currentItem) {
+		l.currentItem = found
+		l.fireChanged()
+	}
+	l.adjustOffset()
+	return ok
 }
 
-// GetItemCount returns the number of items in the list.
-func (l *DeepList) GetItemCount() int {
-	// TODO: should return size of all display sub items
-	return len(l.items)
+// GetExpansionState returns whether or not the sublist of each item is
+// displayed. Only items which have a sublist are included. The map is keyed by
+// the item's main text path (see SetExpansionKeyFunc()), so it survives a
+// rebuild of the list with the same items, see ApplyExpansionState().
+//
+// If two sibling items share the same main text, they map to the same key and
+// the state of the last of these items is stored.
+func (l *DeepList) GetExpansionState() map[string]bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state := make(map[string]bool)
+	var walk func(mainTexts []string, items []*deepListItem)
+	walk = func(mainTexts []string, items []*deepListItem) {
+		for _, item := range items {
+			texts := append(mainTexts[:len(mainTexts):len(mainTexts)], item.MainText)
+			if item.SubList == nil {
+				continue
+			}
+			state[l.getExpansionKey(texts)] = item.SubList.display
+			walk(texts, item.SubList.items)
+		}
+	}
+	walk(nil, l.items)
+	return state
 }
 
-// GetItemText returns an item's texts (main and secondary). Panics if the index
-// is out of range.
-func (l *DeepList) GetItemText(index int) (main, secondary string) {
-	return l.items[index].MainText, l.items[index].SecondaryText
+// ApplyExpansionState shows or hides the sublists of all items found in the
+// given map, as returned by GetExpansionState(). Items whose key is not
+// contained in the map remain unchanged. If two sibling items share the same
+// main text, both receive the same state.
+func (l *DeepList) ApplyExpansionState(state map[string]bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var walk func(parent []int, mainTexts []string, items []*deepListItem)
+	walk = func(parent []int, mainTexts []string, items []*deepListItem) {
+		for index, item := range items {
+			texts := append(mainTexts[:len(mainTexts):len(mainTexts)], item.MainText)
+			if item.SubList == nil {
+				continue
+			}
+			path := append(parent[:len(parent):len(parent)], index)
+			if display, ok := state[l.getExpansionKey(texts)]; ok {
+				l.setExpanded(path, display)
+			}
+			walk(path, texts, item.SubList.items)
+		}
+	}
+	walk(nil, nil, l.items)
+	l.adjustOffset()
+	return l
 }
 
-// SetItemText sets an item's main and secondary text. Panics if the index is
-// out of range.
-func (l *DeepList) SetItemText(index int, main, secondary string) *DeepList {
-	item := l.items[index]
-	item.MainText = main
-	item.SecondaryText = secondary
-	return l
+// specItems returns new items, including their sub items, for the given item
+// specifications.
+func specItems(specs []DeepListItemSpec) []*deepListItem {
+	items := make([]*deepListItem, 0, len(specs))
+	for _, spec := range specs {
+		item := &deepListItem{
+			MainText:      spec.MainText,
+			SecondaryText: spec.SecondaryText,
+			Shortcut:      spec.Shortcut,
+			Selected:      spec.Selected,
+			reference:     spec.Reference,
+		}
+		if len(spec.Items) > 0 {
+			item.SubList = &subList{
+				display: spec.Expanded,
+				items:   specItems(spec.Items),
+			}
+		}
+		items = append(items, item)
+	}
+	return items
 }
 
-// FindItems searches the main and secondary texts for the given strings and
-// returns a list of item indices in which those strings are found. One of the
-// two search strings may be empty, it will then be ignored. Indices are always
-// returned in ascending order.
+// SetQueueUpdateFunc sets the function through which items received by
+// AttachStream() are added to the list, usually the application's
+// [Application.QueueUpdateDraw] function so that the list is redrawn after
+// each new item:
 //
-// If mustContainBoth is set to true, mainSearch must be contained in the main
-// text AND secondarySearch must be contained in the secondary text. If it is
-// false, only one of the two search strings must be contained.
+//	list.SetQueueUpdateFunc(app.QueueUpdateDraw)
 //
-// Set ignoreCase to true for case-insensitive search.
-func (l *DeepList) FindItems(mainSearch, secondarySearch string, mustContainBoth, ignoreCase bool) (indices []int) {
-	if mainSearch == "" && secondarySearch == "" {
-		return
-	}
+// If no such function is set, items are added directly from the stream's
+// goroutine and the application must be redrawn separately. The function must
+// be set before AttachStream() is called.
+func (l *DeepList) SetQueueUpdateFunc(queue func(f func())) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.queueUpdate = queue
+	return l
+}
 
-	if ignoreCase {
-		mainSearch = strings.ToLower(mainSearch)
-		secondarySearch = strings.ToLower(secondarySearch)
+// SetFollow sets a flag which determines whether the list follows its end like
+// "tail -f": While the last item is in view, the list scrolls to keep it in
+// view when items are added. Scrolling away from the end, with the mouse or by
+// moving the selection with the keyboard, suspends following until the list
+// is scrolled back to its end. Enabling follow mode scrolls to the end of the
+// list. The selection is not changed.
+func (l *DeepList) SetFollow(follow bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.follow, l.following = follow, follow
+	if follow {
+		l.scrollToEnd()
 	}
+	return l
+}
 
-	for index, item := range l.items {
-		mainText := item.MainText
-		secondaryText := item.SecondaryText
-		if ignoreCase {
-			mainText = strings.ToLower(mainText)
-			secondaryText = strings.ToLower(secondaryText)
-		}
+// SetStreamAutoScroll sets a flag which determines whether the list scrolls to
+// its end whenever an item received by AttachStream() is added, keeping the
+// newest items in view. The selection is not changed. See SetFollow() for a
+// mode which lets the user scroll away from the end.
+func (l *DeepList) SetStreamAutoScroll(autoScroll bool) *DeepList {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.streamAutoScroll = autoScroll
+	return l
+}
 
-		// strings.Contains() always returns true for a "" search.
-		mainContained := strings.Contains(mainText, mainSearch)
-		secondaryContained := strings.Contains(secondaryText, secondarySearch)
-		if mustContainBoth && mainContained && secondaryContained ||
-			!mustContainBoth && (mainText != "" && mainContained || secondaryText != "" && secondaryContained) {
-			indices = append(indices, index)
+// AttachStream starts a goroutine which adds a top-level item to the end of
+// the list for each item specification received on the given channel, e.g.
+// for log or event viewers. If max is greater than 0, the oldest top-level
+// items (and their sub items) are removed whenever there are more than max of
+// them. The goroutine ends when the channel is closed.
+//
+// Items are added through the function set with SetQueueUpdateFunc(). See
+// SetStreamAutoScroll() for keeping the newest items in view.
+func (l *DeepList) AttachStream(ch <-chan DeepListItemSpec, max int) *DeepList {
+	l.mutex.Lock()
+	queue := l.queueUpdate
+	l.mutex.Unlock()
+
+	go func() {
+		for spec := range ch {
+			spec := spec
+			add := func() {
+				l.addStreamItem(spec, max)
+			}
+			if queue != nil {
+				queue(add)
+			} else {
+				add()
+			}
 		}
-	}
-
-	return
+	}()
+	return l
 }
 
-// Clear removes all items from the list.
-func (l *DeepList) Clear() *DeepList {
-	l.items = nil
-	l.currentItem = []int{0}
-	return l
+// addStreamItem adds an item received by AttachStream() to the end of the list
+// and removes the oldest top-level items if there are more than max of them.
+func (l *DeepList) addStreamItem(spec DeepListItemSpec, max int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.insertItem(-1, specItems([]DeepListItemSpec{spec})[0])
+	for max > 0 && len(l.items) > max {
+		l.removePath([]int{0})
+	}
+	if l.streamAutoScroll || l.following {
+		l.scrollToEnd()
+	}
 }
 
 // Draw draws this primitive onto the screen.
 func (l *DeepList) Draw(screen tcell.Screen) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.updateTitle()
 	l.Box.DrawForSubclass(screen, l)
+	l.draw(screen)
+	l.hoverStale = true
+}
+
+// draw implements Draw() without drawing the box.
+func (l *DeepList) draw(screen tcell.Screen) {
+	l.normalizeCurrentItem()
+	l.drawnRows = l.drawnRows[:0]
 
 	// Determine the dimensions.
 	x, y, width, height := l.GetInnerRect()
@@ -621,18 +5049,63 @@ func (l *DeepList) Draw(screen tcell.Screen) {
 		bottomLimit = totalHeight
 	}
 
+	// Draw the placeholder of an empty list.
+	if len(l.items) == 0 {
+		if l.emptyText != "" && height > 0 {
+			printWithStyle(screen, l.emptyText, x, y+(height-1)/2, 0, width, AlignCenter, l.emptyTextStyle, true)
+		}
+		return
+	}
+
+	// Reserve space for the scrollbar.
+	rows := l.visibleRows()
+	scrollbarWidth := l.scrollbarWidth(rows)
+	width -= scrollbarWidth
+	if width < 0 {
+		width = 0
+	}
+	rowX, rowWidth := x, width
+	l.drawnX, l.drawnWidth = rowX, rowWidth
+
+	// Leave the item padding empty.
+	x += l.itemPaddingLeft
+	width -= l.itemPaddingLeft + l.itemPaddingRight
+	if width < 0 {
+		width = 0
+	}
+
 	// Do we show any shortcuts?
-	var showShortcuts bool
-	for _, item := range l.items {
-		if item.Shortcut != 0 {
-			showShortcuts = true
-			x += 4
-			width -= 4
-			break
+	// In right-to-left mode, the shortcut and icon columns are on the right.
+	// The columns are narrowed to stay within the list's (padded) inner area.
+	shortcutWidth := l.shortcutWidth()
+	if shortcutWidth > width {
+		shortcutWidth = width
+	}
+	showShortcuts := shortcutWidth > 0
+	shortcutX, shortcutAlign := x, AlignRight
+	width -= shortcutWidth
+	if l.rtl {
+		shortcutX, shortcutAlign = x+width+1, AlignLeft
+	} else {
+		x += shortcutWidth
+	}
+
+	// Reserve the icon column.
+	iconX, iconWidth := x, 0
+	if l.iconColumn {
+		iconWidth = deepListIconWidth
+		if iconWidth > width {
+			iconWidth = width
+		}
+		width -= iconWidth
+		if l.rtl {
+			iconX = x + width + iconWidth - 1
+		} else {
+			x += iconWidth
 		}
 	}
 
-	if l.horizontalOffset < 0 {
+	if l.horizontalOffset < 0 || l.ellipsis {
 		l.horizontalOffset = 0
 	}
 
@@ -640,91 +5113,286 @@ func (l *DeepList) Draw(screen tcell.Screen) {
 	var (
 		maxWidth    int  // The maximum printed item width.
 		overflowing bool // Whether a text's end exceeds the right border.
+		loading     bool // Whether a spinner was drawn.
 	)
-PARENT:
-	for index, item := range l.items {
-		if index < l.itemOffset {
-			continue
+	if scrollbarWidth > 0 {
+		l.drawScrollbar(screen, rows, rowX+rowWidth, y, bottomLimit-y, width)
+	}
+	if header := l.stickyHeader(rows); header != nil && y < bottomLimit {
+		indent := rowIndent(*header, width)
+		headerX, align := x+indent, AlignLeft
+		if l.rtl {
+			headerX, align = x, AlignRight
 		}
-
+		printWithStyle(screen, header.item.getExpandedMainText(l.tabSize), headerX, y, 0, width-indent, align, l.headerStyle, true)
+		y++
+	}
+	for index := l.itemOffset; index < len(rows); index++ {
+		row := rows[index]
 		if y >= bottomLimit {
 			break
 		}
-
-		// Shortcuts.
-		if showShortcuts && item.Shortcut != 0 {
-			printWithStyle(screen, fmt.Sprintf("(%s)", string(item.Shortcut)), x-5, y, 0, 4, AlignRight, l.shortcutStyle, true)
-		}
-
-		// Main text.
-		_, printedWidth, _, end := printWithStyle(screen, item.MainText, x, y, l.horizontalOffset, width, AlignLeft, l.mainTextStyle, true)
-		if printedWidth > maxWidth {
-			maxWidth = printedWidth
+		drawnHeight := l.rowHeight(row, width)
+		if drawnHeight > bottomLimit-y {
+			drawnHeight = bottomLimit - y
 		}
-		if end < len(item.MainText) {
-			overflowing = true
+		l.drawnRows = append(l.drawnRows, deepListDrawnRow{path: row.path, y: y, height: drawnHeight})
+
+		item := row.item
+		depth := len(row.path) - 1
+
+		// Row background: the select animation, the hover highlight, or
+		// alternating stripes.
+		animated := l.animationFrames > 0 && equals(row.path, l.animatedItem)
+		hovered := l.mouseHover && !l.hoverStale && selectable(row) &&
+			equals(row.path, l.hoverItem) && !equals(row.path, l.currentItem)
+		if animated || hovered || l.alternateRowColor != tcell.ColorDefault && index%2 == 1 {
+			backgroundStyle := tcell.StyleDefault.Background(l.alternateRowColor)
+			if animated {
+				backgroundStyle = l.animationStyle
+			} else if hovered {
+				backgroundStyle = l.hoverStyle
+			}
+			for ry := y; ry < y+l.rowHeight(row, width) && ry < bottomLimit; ry++ {
+				for rx := rowX; rx < rowX+rowWidth; rx++ {
+					screen.SetContent(rx, ry, ' ', nil, backgroundStyle)
+				}
+			}
 		}
 
-		// Background color of selected text.
-		if index == l.currentItem[0] && (!l.selectedFocusOnly || l.HasFocus()) {
-			textWidth := width
-			if !l.highlightFullLine {
-				if w := TaggedStringWidth(item.MainText); w < textWidth {
-					textWidth = w
+		// Sub items are indented and drawn in the secondary text style.
+		indent := rowIndent(row, width)
+		textX, textWidth := x+indent, width-indent
+		if l.rtl {
+			textX = x
+		}
+		if l.indentRune != 0 && indent > 0 {
+			indentX := x
+			if l.rtl {
+				indentX = x + textWidth
+			}
+			for ry := y; ry < y+l.rowHeight(row, width) && ry < bottomLimit; ry++ {
+				for sx := indentX; sx < indentX+indent; sx++ {
+					_, _, existingStyle, _ := screen.GetContent(sx, ry)
+					_, background, _ := existingStyle.Decompose()
+					screen.SetContent(sx, ry, l.indentRune, nil, l.indentStyle.Background(background))
 				}
 			}
+		}
 
-			mainTextColor, _, _ := l.mainTextStyle.Decompose()
-			for bx := 0; bx < textWidth; bx++ {
-				m, c, style, _ := screen.GetContent(x+bx, y)
-				fg, _, _ := style.Decompose()
-				style = l.selectedStyle
-				if fg != mainTextColor {
-					style = style.Foreground(fg)
-				}
-				screen.SetContent(x+bx, y, m, c, style)
+		// Separators.
+		if item.separator {
+			for sx := textX; sx < textX+textWidth; sx++ {
+				_, _, style, _ := screen.GetContent(sx, y)
+				screen.SetContent(sx, y, l.separatorRune, nil, style.Foreground(Styles.GraphicsColor))
 			}
+			y++
+			continue
 		}
-		y++
 
-		if y >= bottomLimit {
-			break
+		style, secondaryStyle := l.rowStyles(row)
+
+		// Shortcuts.
+		if label := l.shortcutLabel(item); showShortcuts && depth == 0 && label != "" && selectable(row) {
+			printWithStyle(screen, label, shortcutX, y, 0, shortcutWidth-1, shortcutAlign, l.shortcutStyle, true)
+		}
+
+		// Icons.
+		if iconWidth > 0 && item.icon != 0 {
+			screen.SetContent(iconX, y, item.icon, nil, item.iconStyle)
 		}
 
-		// Secondary text.
-		if l.showSecondaryText {
-			_, printedWidth, _, end := printWithStyle(screen, item.SecondaryText, x, y, l.horizontalOffset, width, AlignLeft, l.secondaryTextStyle, true)
-			if printedWidth > maxWidth {
-				maxWidth = printedWidth
+		// Horizontal scrolling, for all items or per item. With per-item
+		// scrolling, only the selected item affects the arrow keys.
+		current := equals(row.path, l.currentItem)
+		horizontalOffset := l.horizontalOffset
+		if l.perItemScroll {
+			if maxOffset := l.maxItemOffset(row, textWidth); item.horizontalOffset > maxOffset || l.ellipsis {
+				item.horizontalOffset = maxOffset
 			}
-			if end < len(item.SecondaryText) {
-				overflowing = true
+			if item.horizontalOffset < 0 || l.ellipsis {
+				item.horizontalOffset = 0
 			}
+			horizontalOffset = item.horizontalOffset
+		}
+		tracksOverflow := !l.perItemScroll || current
 
+		// Main text. Wrapped text is not scrolled horizontally.
+		skipWidth := horizontalOffset
+		if l.wrap {
+			skipWidth = 0
+		}
+		selected := (current && !l.multiSelect || l.multiSelection[item]) && !animated && (!l.selectedFocusOnly || l.HasFocus())
+
+		// The prefix scrolls with the main text. Wrapped lines are indented by
+		// its width.
+		prefixWidth := TaggedStringWidth(item.prefix)
+		prefixShown := prefixWidth - skipWidth
+		if prefixShown < 0 {
+			prefixShown = 0
+		} else if prefixShown > textWidth {
+			prefixShown = textWidth
+		}
+		// Trailing text is drawn at the right edge, the main text ends before it.
+		trailingWidth := trailingTextWidth(item)
+		lineX, lineWidth, lineSkip := textX+prefixShown, textWidth-prefixShown-trailingWidth, skipWidth-prefixWidth
+		if lineWidth < 0 {
+			lineWidth = 0
+		}
+		if lineSkip < 0 {
+			lineSkip = 0
+		}
+		// Items which are loading show a spinner after their main text.
+		// Collapsed items may show the number of their hidden sub items there.
+		var (
+			countText  string
+			countStyle = l.childCountStyle
+		)
+		if item.loading {
+			countText = string(deepListSpinner[l.spinnerFrame%len(deepListSpinner)])
+			countStyle = style
+			loading = true
+		} else if l.showChildCount && item.SubList != nil && !l.subListShown(row.path, item) {
+			if count := childCount(item, l.childCountTotal); count > 0 {
+				countText = fmt.Sprintf("(%d)", count)
+			}
+		}
+		lines := l.mainTextLines(item, textWidth-prefixWidth-trailingWidth)
+		trailingY := y
+		if len(l.columns) > 0 {
+			// Tab-separated fields in columns.
+			lines = nil
+			if prefixShown > 0 {
+				printWithStyle(screen, item.prefix, textX, y, skipWidth, prefixShown, AlignLeft, l.prefixStyle, true)
+			}
+			columnsWidth := l.drawColumns(screen, item, x, y, indent+prefixShown, width-trailingWidth, style)
+			if selected {
+				highlightWidth := textWidth
+				if !l.highlightFullLine {
+					highlightWidth = columnsWidth - indent
+				}
+				l.highlightSelection(screen, textX, y, highlightWidth, style)
+			}
 			y++
 		}
+		for lineIndex, line := range lines {
+			// Centered or right-aligned lines are moved to the right, together
+			// with the prefix and the count.
+			var shift int
+			if l.alignment() != AlignLeft {
+				w := TaggedStringWidth(line) - lineSkip
+				if w < 0 {
+					w = 0
+				}
+				w += prefixShown
+				if countText != "" && lineIndex == len(lines)-1 {
+					w += 1 + TaggedStringWidth(countText)
+				}
+				shift = l.alignShift(w, textWidth-trailingWidth)
+			}
+			lineX, lineWidth := lineX+shift, lineWidth-shift
 
-		if item.SubList != nil && item.SubList.display {
-			for _, subItem := range item.SubList.items {
-				_, printedWidth, _, end := printWithStyle(screen, subItem.MainText, x, y, l.horizontalOffset, width, AlignLeft, l.secondaryTextStyle, true)
-				if printedWidth > maxWidth {
-					maxWidth = printedWidth
+			if lineIndex == 0 && prefixShown > 0 {
+				printWithStyle(screen, item.prefix, textX+shift, y, skipWidth, prefixShown, AlignLeft, l.prefixStyle, true)
+			}
+			_, printedWidth, _, end := printWithStyle(screen, line, lineX, y, lineSkip, lineWidth, AlignLeft, style, true)
+			if indent+prefixShown+printedWidth > maxWidth {
+				maxWidth = indent + prefixShown + printedWidth
+			}
+			if end < len(line) {
+				if (l.ellipsis || trailingWidth > 0) && printedWidth > 0 {
+					// Cut off the main text.
+					_, _, style, _ := screen.GetContent(lineX+lineWidth-1, y)
+					printWithStyle(screen, string(SemigraphicsHorizontalEllipsis), lineX+lineWidth-1, y, 0, 1, AlignLeft, style, false)
 				}
-				if end < len(item.MainText) {
+				if !l.ellipsis && tracksOverflow {
 					overflowing = true
 				}
+			} else if countText != "" && lineIndex == len(lines)-1 && printedWidth+1 < lineWidth {
+				_, countWidth, _, _ := printWithStyle(screen, countText, lineX+printedWidth+1, y, 0, lineWidth-printedWidth-1, AlignLeft, countStyle, true)
+				if indent+prefixShown+printedWidth+1+countWidth > maxWidth {
+					maxWidth = indent + prefixShown + printedWidth + 1 + countWidth
+				}
+			}
+
+			// Background color of selected text.
+			if selected {
+				highlightX, highlightWidth := textX, textWidth
+				if !l.highlightFullLine {
+					highlightX = textX + shift
+					w := item.getMainTextWidth(l.tabSize)
+					if l.wrap {
+						w = TaggedStringWidth(line)
+					}
+					highlightWidth = prefixShown + lineWidth
+					if w += prefixShown; w < highlightWidth {
+						highlightWidth = w
+					}
+				}
 
-				// show selection hghlighted
+				l.highlightSelection(screen, highlightX, y, highlightWidth, style)
+			}
+			l.drawSearchHighlight(screen, line, lineX, y, lineSkip, lineWidth)
+			y++
 
-				y++
+			if y >= bottomLimit {
+				break
+			}
+		}
+
+		// The trailing text keeps its own style on selected items. Only the
+		// background follows the selection.
+		if trailingWidth > 0 {
+			w := trailingWidth - 1
+			if w > textWidth {
+				w = textWidth
+			}
+			printWithStyle(screen, item.trailingText, textX+textWidth-w, trailingY, 0, w, AlignLeft, item.trailingStyle, true)
+		}
+
+		if y >= bottomLimit {
+			break
+		}
+
+		// Secondary text, one row per line.
+		if l.showsSecondaryText(row) {
+			for _, line := range secondaryTextLines(item) {
+				lineX, lineWidth := textX, textWidth
+				if l.alignment() != AlignLeft {
+					shift := l.alignShift(TaggedStringWidth(line)-horizontalOffset, textWidth)
+					lineX, lineWidth = lineX+shift, lineWidth-shift
+				}
+				_, printedWidth, _, end := printWithStyle(screen, line, lineX, y, horizontalOffset, lineWidth, AlignLeft, secondaryStyle, true)
+				if indent+printedWidth > maxWidth {
+					maxWidth = indent + printedWidth
+				}
+				if end < len(line) {
+					if !l.ellipsis {
+						overflowing = overflowing || tracksOverflow
+					} else if printedWidth > 0 {
+						_, _, style, _ := screen.GetContent(textX+textWidth-1, y)
+						printWithStyle(screen, string(SemigraphicsHorizontalEllipsis), textX+textWidth-1, y, 0, 1, AlignLeft, style, false)
+					}
+				}
+				if selected && l.highlightFullLine {
+					l.highlightSelection(screen, textX, y, textWidth, secondaryStyle)
+				}
+				l.drawSearchHighlight(screen, line, lineX, y, horizontalOffset, lineWidth)
 
+				y++
 				if y >= bottomLimit {
-					break PARENT
+					break
 				}
 			}
-
 		}
+	}
 
+	// Advance the spinner and the select animation.
+	if loading {
+		l.spinnerFrame++
+	}
+	if l.animationFrames > 0 {
+		l.animationFrames--
 	}
 
 	// We don't want the item text to get out of view. If the horizontal offset
@@ -732,206 +5400,463 @@ PARENT:
 	// as calculating everything up front.)
 	if l.horizontalOffset > 0 && maxWidth < width {
 		l.horizontalOffset -= width - maxWidth
-		l.Draw(screen)
+		l.draw(screen)
 	}
 	l.overflowing = overflowing
 }
 
 // adjustOffset adjusts the vertical offset to keep the current selection in
-// view.
+// view. It does nothing during batch updates.
 func (l *DeepList) adjustOffset() {
+	if l.batching {
+		return
+	}
 	_, _, _, height := l.GetInnerRect()
 	if height == 0 {
 		return
 	}
-	currentItemOffset := getOffset(0, 0, l.currentItem, l.items)
-	if currentItemOffset < l.itemOffset {
-		l.itemOffset = currentItemOffset
-	} else if l.showSecondaryText {
-		if 2*(currentItemOffset-l.itemOffset) >= height-1 {
-			l.itemOffset = (2*currentItemOffset + 3 - height) / 2
-		}
-	} else {
-		if currentItemOffset-l.itemOffset >= height {
-			l.itemOffset = currentItemOffset + 1 - height
+	rows := l.visibleRows()
+	currentRow := l.currentRowIndex(rows)
+	if currentRow < 0 {
+		return
+	}
+	width := l.contentWidth(rows)
+	starts := l.lineStarts(rows, width)
+
+	if l.selectedVisibility == SelectedVisibleCenter {
+		// Fill half of the remaining rows with the items above the current
+		// item. Then add further items above it if the items below it don't
+		// fill the rest.
+		above := (height - (starts[currentRow+1] - starts[currentRow])) / 2
+		below := starts[len(rows)] - starts[currentRow]
+		offset, used := currentRow, 0
+		for offset > 0 {
+			rowHeight := starts[offset] - starts[offset-1]
+			if used+rowHeight > above && used+rowHeight+below > height {
+				break
+			}
+			offset--
+			used += rowHeight
 		}
+		l.itemOffset = offset
+	}
+
+	if currentRow < l.itemOffset {
+		l.itemOffset = currentRow
+		return
+	}
+
+	// Skip items at the top until the current item fits, below the sticky
+	// header if one is drawn for the offset.
+	end := starts[currentRow+1]
+	if end-starts[l.itemOffset] > height {
+		l.itemOffset += sort.Search(currentRow-l.itemOffset, func(skipped int) bool {
+			return end-starts[l.itemOffset+skipped] <= height
+		})
 	}
+	if l.itemOffset < currentRow && end-starts[l.itemOffset] == height && l.stickyHeader(rows) != nil {
+		l.itemOffset++
+	}
+}
+
+// Focus is called when this primitive receives focus. The selected item is
+// scrolled into view in case the list changed while it didn't have focus.
+func (l *DeepList) Focus(delegate func(p Primitive)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.normalizeCurrentItem()
+	l.adjustOffset()
+	l.Box.Focus(delegate)
 }
 
-// InputHandler returns the handler for this primitive.
+// InputHandler returns the handler for this primitive. A function installed
+// with SetInputCapture() is called first. The list only handles the event that
+// function returns, which may differ from the original event, and nothing at
+// all if it returns nil. All of the list's own key bindings, including
+// shortcuts, are processed after that.
 func (l *DeepList) InputHandler() func(event *tcell.EventKey, setFocus func(p Primitive)) {
 	return l.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p Primitive)) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		defer l.updateFollowing()
+
 		if event.Key() == tcell.KeyEscape {
 			if l.done != nil {
-				l.done()
+				l.unlocked(l.done)
+			}
+			if l.doneWithSelection != nil {
+				if item := l.itemAt(l.currentItem); item != nil {
+					path := append([]int(nil), l.currentItem...)
+					handler := l.doneWithSelection
+					mainText, secondaryText, shortcut := item.MainText, item.SecondaryText, item.Shortcut
+					l.unlocked(func() { handler(path, mainText, secondaryText, shortcut) })
+				} else {
+					handler := l.doneWithSelection
+					l.unlocked(func() { handler(nil, "", "", 0) })
+				}
+			}
+			return
+		}
+		rows := l.visibleRows()
+		if len(rows) == 0 {
+			return
+		}
+
+		l.normalizeCurrentItem()
+		previousItem := append([]int(nil), l.currentItem...)
+
+		if l.clipboard != nil && event.Key() == l.copyKey {
+			if item := l.itemAt(l.currentItem); item != nil {
+				text := stripTags(item.MainText)
+				if l.copySecondaryText && item.SecondaryText != "" {
+					text += "\n" + stripTags(item.SecondaryText)
+				}
+				handler := l.clipboard
+				l.unlocked(func() { handler(text) })
 			}
 			return
-		} else if len(l.items) == 0 {
+		}
+
+		// Expand or collapse the selected item and all of its descendants.
+		if l.expandRecursiveKey != 0 && event.Key() == tcell.KeyRune && event.Rune() == l.expandRecursiveKey {
+			if item := l.itemAt(l.currentItem); item != nil && item.SubList != nil && len(item.SubList.items) > 0 {
+				expanded := event.Modifiers()&tcell.ModAlt == 0
+				path := append([]int(nil), l.currentItem...)
+				l.setTreeExpanded(path, item.SubList.items, expanded)
+				l.toggleExpanded(path, expanded)
+				if !equals(l.currentItem, previousItem) {
+					l.fireChanged()
+				}
+				l.adjustOffset()
+				return
+			}
+		}
+
+		// Show the help text of the selected item.
+		if l.help != nil && l.helpKey != 0 && event.Key() == tcell.KeyRune && event.Rune() == l.helpKey {
+			if item := l.itemAt(l.currentItem); item != nil && item.help != "" {
+				path := append([]int(nil), l.currentItem...)
+				handler, help := l.help, item.help
+				l.unlocked(func() { handler(path, help) })
+				return
+			}
+		}
+
+		// Jump to a sibling.
+		if l.siblingKeys && event.Modifiers() == l.siblingModifiers &&
+			(event.Key() == l.nextSiblingKey || event.Key() == l.previousSiblingKey) {
+			direction := 1
+			if event.Key() == l.previousSiblingKey {
+				direction = -1
+			}
+			if l.moveToSibling(rows, direction) {
+				l.fireChanged()
+				l.adjustOffset()
+			}
+			return
+		}
+
+		// Jump to the parent item. A pending type-ahead search is edited with
+		// Backspace first.
+		if key := event.Key(); l.parentKey != 0 && (key == l.parentKey || isBackspace(key) && isBackspace(l.parentKey)) &&
+			!(isBackspace(key) && l.typeAhead && l.typeAheadBuffer != "") {
+			if parent := l.selectableAncestor(l.currentItem); parent != nil {
+				l.currentItem = parent
+				l.fireChanged()
+				l.adjustOffset()
+			}
 			return
 		}
 
-		previousItem := l.currentItem
+		// Translate Vim keys into the keys they stand for. "h" and "l" only
+		// navigate the tree.
+		var vimTree bool
+		if l.vimKeys && event.Key() == tcell.KeyRune {
+			switch event.Rune() {
+			case 'j':
+				event = tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+			case 'k':
+				event = tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+			case 'g':
+				event = tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModNone)
+			case 'G':
+				event = tcell.NewEventKey(tcell.KeyEnd, 0, tcell.ModNone)
+			case 'h':
+				event = tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModNone)
+				vimTree = true
+			case 'l':
+				event = tcell.NewEventKey(tcell.KeyRight, 0, tcell.ModNone)
+				vimTree = true
+			}
+		}
 
 		switch key := event.Key(); key {
 		case tcell.KeyTab, tcell.KeyDown:
-			l.currentItem = moveSelectionIndex(0, l.currentItem, l.items, 1)
+			if key == tcell.KeyDown && l.multiSelect && event.Modifiers()&tcell.ModShift != 0 {
+				l.extendSelection(1, previousItem)
+				break
+			}
+			l.moveSelection(1, l.wrapAround)
+			l.fireBoundary(previousItem, 1)
 		case tcell.KeyBacktab, tcell.KeyUp:
-			l.currentItem = moveSelectionIndex(0, l.currentItem, l.items, -1)
+			if key == tcell.KeyUp && l.multiSelect && event.Modifiers()&tcell.ModShift != 0 {
+				l.extendSelection(-1, previousItem)
+				break
+			}
+			l.moveSelection(-1, l.wrapAround)
+			l.fireBoundary(previousItem, -1)
 		case tcell.KeyRight:
+			if l.treeNavigation || vimTree {
+				if item := l.itemAt(l.currentItem); item != nil && item.SubList != nil && len(item.SubList.items) > 0 && l.aboveMaxDepth(l.currentItem) {
+					if !item.SubList.display {
+						l.toggleExpanded(l.currentItem, true)
+					} else if child := firstSelectableChild(rows, l.currentRowIndex(rows)); child >= 0 {
+						l.currentItem = append([]int(nil), rows[child].path...)
+					}
+					break
+				}
+			}
+			if vimTree {
+				break
+			}
 			if l.overflowing {
-				l.horizontalOffset += 2 // We shift by 2 to account for two-cell characters.
+				*l.scrollOffset() += l.scrollJump(event.Modifiers())
 			} else {
-				l.currentItem = moveSelectionIndex(0, l.currentItem, l.items, 1)
+				l.moveSelection(1, l.wrapAround)
 			}
 		case tcell.KeyLeft:
-			if l.horizontalOffset > 0 {
-				l.horizontalOffset -= 2
+			if l.treeNavigation || vimTree {
+				if item := l.itemAt(l.currentItem); item != nil && l.subListShown(l.currentItem, item) && len(item.SubList.items) > 0 {
+					l.toggleExpanded(l.currentItem, false)
+					break
+				} else if parent := l.selectableAncestor(l.currentItem); parent != nil {
+					l.currentItem = parent
+					break
+				}
+			}
+			if vimTree {
+				break
+			}
+			if offset := l.scrollOffset(); *offset > 0 {
+				*offset -= l.scrollJump(event.Modifiers())
+				if *offset < 0 {
+					*offset = 0
+				}
 			} else {
-				l.currentItem = moveSelectionIndex(0, l.currentItem, l.items, -1)
+				l.moveSelection(-1, l.wrapAround)
 			}
 		case tcell.KeyHome:
-			l.currentItem = []int{0}
+			if index := selectableRow(rows, 0, 1, false); index >= 0 {
+				l.currentItem = append([]int(nil), rows[index].path...)
+			}
 		case tcell.KeyEnd:
-			l.currentItem = []int{len(l.items) - 1}
+			if index := selectableRow(rows, len(rows)-1, -1, false); index >= 0 {
+				l.currentItem = append([]int(nil), rows[index].path...)
+			}
 		case tcell.KeyPgDn:
-			_, _, _, height := l.GetInnerRect()
-			l.currentItem = moveSelectionIndex(0, l.currentItem, l.items, height)
-			//if l.currentItem >= len(l.items) {
-			//	l.currentItem = len(l.items) - 1
-			//}
-		/*case tcell.KeyPgUp:
-		_, _, _, height := l.GetInnerRect()
-		l.currentItem -= height
-		if l.currentItem < 0 {
-			l.currentItem = 0
-		}
-		*/
+			l.movePage(1)
+		case tcell.KeyPgUp:
+			l.movePage(-1)
 		case tcell.KeyEnter:
-			if l.currentItem[0] >= 0 && l.currentItem[0] < len(l.items) {
-				item := l.items[l.currentItem[0]]
-				if item.Selected != nil {
-					item.Selected()
-				}
-				if l.selected != nil {
-					l.selected(l.currentItem, item.MainText, item.SecondaryText, item.Shortcut)
-				}
+			// Any changes made by the callbacks are reported by the functions
+			// they call.
+			l.selectItem(l.currentItem)
+			previousItem = append([]int(nil), l.currentItem...)
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if l.typeAhead && l.typeAheadBuffer != "" {
+				buffer := []rune(l.typeAheadBuffer)
+				l.typeAheadBuffer = string(buffer[:len(buffer)-1])
+				l.typeAheadTime = time.Now()
+				l.typeAheadSearch(false)
 			}
 		case tcell.KeyRune:
 			ch := event.Rune()
 			if ch != ' ' {
 				// It's not a space bar. Is it a shortcut?
-				var found bool
-				for index, item := range l.items {
-					if item.Shortcut == ch {
-						// We have a shortcut.
-						found = true
-						l.currentItem = []int{index}
-						break
-					}
+				index, pending := l.matchShortcut(rows, ch)
+				if pending {
+					break // Wait for the rest of a string shortcut.
 				}
-				if !found {
+				if index >= 0 {
+					// We have a shortcut.
+					l.currentItem = append([]int(nil), rows[index].path...)
+				} else {
+					if l.typeAhead {
+						// Extend the type-ahead search.
+						if time.Since(l.typeAheadTime) > typeAheadTimeout {
+							l.typeAheadBuffer = ""
+						}
+						l.typeAheadBuffer += string(ch)
+						l.typeAheadTime = time.Now()
+						l.typeAheadSearch(len(l.typeAheadBuffer) == 1)
+					}
 					break
 				}
 			}
-			item := l.items[l.currentItem[0]]
-			if item.Selected != nil {
-				item.Selected()
-			}
-			if l.selected != nil {
-				l.selected(l.currentItem, item.MainText, item.SecondaryText, item.Shortcut)
-			}
-		}
-
-		if l.currentItem[0] < 0 {
-			if l.wrapAround {
-				l.currentItem[0] = len(l.items) - 1
-			} else {
-				l.currentItem[0] = 0
-			}
-		} else if l.currentItem[0] >= len(l.items) {
-			if l.wrapAround {
-				l.currentItem[0] = 0
-			} else {
-				l.currentItem[0] = len(l.items) - 1
+			// Report the move to the shortcut's item before selecting it, like
+			// a click before a double click. Any changes made by the callbacks
+			// are reported by the functions they call.
+			if !equals(l.currentItem, previousItem) {
+				l.fireChanged()
+				l.adjustOffset()
 			}
+			l.selectItem(l.currentItem)
+			previousItem = append([]int(nil), l.currentItem...)
 		}
 
-		if l.currentItem[0] != previousItem[0] && l.currentItem[0] < len(l.items) {
-			if l.changed != nil {
-				item := l.items[l.currentItem[0]]
-				l.changed(l.currentItem, item.MainText, item.SecondaryText, item.Shortcut)
-			}
+		// The callbacks may have modified the list, so only the current state
+		// is used from here on.
+		if !equals(l.currentItem, previousItem) {
+			l.fireChanged()
 			l.adjustOffset()
 		}
+		l.fireSelectionChanged()
 	})
 }
 
-// indexAtPoint returns the index of the list item found at the given position
-// or a negative value if there is no such list item.
-func (l *DeepList) indexAtPoint(x, y int) int {
+// indexAtPoint returns the path of the list item found at the given position
+// or nil if there is no such list item. The visible rows are walked from the
+// offset, taking the actual height of each item into account (which depends on
+// secondary texts and wrapping), so this works for any mix of items and sub
+// items. The row of a sticky header does not belong to any item.
+func (l *DeepList) indexAtPoint(x, y int) []int {
 	rectX, rectY, width, height := l.GetInnerRect()
-	if rectX < 0 || rectX >= rectX+width || y < rectY || y >= rectY+height {
-		return -1
+	if rectX < 0 || x < rectX || x >= rectX+width || y < rectY || y >= rectY+height {
+		return nil
 	}
 
-	index := y - rectY
-	if l.showSecondaryText {
-		index /= 2
+	rows := l.visibleRows()
+	if l.stickyHeader(rows) != nil {
+		if y == rectY {
+			return nil
+		}
+		rectY++
 	}
-	index += l.itemOffset
-
-	if index >= len(l.items) {
-		return -1
+	contentWidth := l.contentWidth(rows)
+	for index := l.itemOffset; index < len(rows); index++ {
+		rowHeight := l.rowHeight(rows[index], contentWidth)
+		if y < rectY+rowHeight {
+			return rows[index].path
+		}
+		rectY += rowHeight
 	}
-	return index
+	return nil
 }
 
-// MouseHandler returns the mouse handler for this primitive.
+// MouseHandler returns the mouse handler for this primitive. A click selects
+// the item under the mouse cursor. A double click first selects the item
+// (through its first click) and then toggles the display of the item's sublist
+// or, if the item has no sub items, calls its callback and the list's
+// "selected" callback like the Enter key.
+//
+// Clicks, double clicks, and scroll wheel events inside the list are consumed,
+// as are right clicks if a handler was set with SetRightClickFunc(). Events
+// outside the list are not consumed.
 func (l *DeepList) MouseHandler() func(action MouseAction, event *tcell.EventMouse, setFocus func(p Primitive)) (consumed bool, capture Primitive) {
 	return l.WrapMouseHandler(func(action MouseAction, event *tcell.EventMouse, setFocus func(p Primitive)) (consumed bool, capture Primitive) {
-		if !l.InRect(event.Position()) {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		x, y := event.Position()
+
+		// Dragging the scrollbar continues outside the list.
+		if l.scrollbarDragging {
+			_, rectY, _, _ := l.GetInnerRect()
+			switch action {
+			case MouseMove:
+				l.scrollToLine(y - rectY)
+				l.updateFollowing()
+				return true, l
+			case MouseLeftUp:
+				l.scrollbarDragging = false
+				return true, nil
+			}
+		}
+
+		if !l.InRect(x, y) {
+			if l.hoverItem != nil {
+				previous := l.hoverItem
+				l.hoverItem = nil
+				l.hoverHelp(previous, nil)
+			}
 			return false, nil
 		}
 
-		/*
-			// Process mouse event.
-			switch action {
-			case MouseLeftClick:
-				setFocus(l)
-				index := l.indexAtPoint(event.Position())
-				if index != -1 {
-					item := l.items[index]
-					if item.Selected != nil {
-						item.Selected()
-					}
-					if l.selected != nil {
-						l.selected(index, item.MainText, item.SecondaryText, item.Shortcut)
-					}
-					if index != l.currentItem {
-						if l.changed != nil {
-							l.changed(index, item.MainText, item.SecondaryText, item.Shortcut)
-						}
-						l.adjustOffset()
-					}
-					l.currentItem = index
-				}
-				consumed = true
-			case MouseScrollUp:
-				if l.itemOffset > 0 {
-					l.itemOffset--
+		// Track the item under the mouse.
+		if l.mouseHover {
+			previous := l.hoverItem
+			l.hoverItem = append([]int(nil), l.indexAtPoint(x, y)...)
+			l.hoverStale = false
+			l.hoverHelp(previous, l.hoverItem)
+			if action == MouseMove {
+				return true, nil
+			}
+		}
+
+		// Clicking the scrollbar starts dragging it.
+		if action == MouseLeftDown {
+			rectX, rectY, width, height := l.GetInnerRect()
+			if l.scrollbarWidth(l.visibleRows()) > 0 && x == rectX+width-1 && y >= rectY && y < rectY+height {
+				l.unlocked(func() { setFocus(l) })
+				l.scrollbarDragging = true
+				l.scrollToLine(y - rectY)
+				l.updateFollowing()
+				return true, l
+			}
+		}
+
+		// Process mouse event. The first click of a double click selects the
+		// item so the double click itself only needs to activate it.
+		switch action {
+		case MouseLeftClick:
+			l.unlocked(func() { setFocus(l) })
+			if path := l.indexAtPoint(x, y); path != nil && l.selectablePath(path) {
+				if l.multiSelect {
+					l.clickSelection(path, event.Modifiers())
 				}
-				consumed = true
-			case MouseScrollDown:
-				lines := len(l.items) - l.itemOffset
-				if l.showSecondaryText {
-					lines *= 2
+				// Report the new selection once, and only if it changed.
+				if !equals(path, l.currentItem) {
+					l.currentItem = append([]int(nil), path...)
+					l.fireChanged()
+					l.adjustOffset()
 				}
-				if _, _, _, height := l.GetInnerRect(); lines > height {
-					l.itemOffset++
+				l.fireSelectionChanged()
+			}
+			consumed = true
+		case MouseLeftDoubleClick:
+			l.unlocked(func() { setFocus(l) })
+			if path := l.indexAtPoint(x, y); path != nil && l.selectablePath(path) {
+				if item := l.itemAt(path); item.SubList != nil && len(item.SubList.items) > 0 {
+					l.toggleSubListDisplay(path)
+				} else {
+					l.selectItem(path)
 				}
+			}
+			consumed = true
+		case MouseRightClick:
+			if l.rightClick != nil {
+				l.unlocked(func() { setFocus(l) })
+				path := append([]int(nil), l.indexAtPoint(x, y)...)
+				handler := l.rightClick
+				l.unlocked(func() { handler(path, x, y) })
 				consumed = true
 			}
-		*/
+		case MouseScrollUp:
+			if l.itemOffset > 0 {
+				l.itemOffset--
+			}
+			l.updateFollowing()
+			consumed = true
+		case MouseScrollDown:
+			rows := l.visibleRows()
+			_, _, _, height := l.GetInnerRect()
+			if starts := l.lineStarts(rows, l.contentWidth(rows)); l.itemOffset < len(rows) && starts[len(rows)]-starts[l.itemOffset] > height {
+				l.itemOffset++
+			}
+			l.updateFollowing()
+			consumed = true
+		}
 
 		return
 	})
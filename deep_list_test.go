@@ -0,0 +1,1297 @@
+package tview
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestDeepList returns a list with the items "alpha", "beta" (with the
+// displayed sub items "b1" and "b2"), and "gamma".
+func newTestDeepList() *DeepList {
+	l := NewDeepList().ShowSecondaryText(false)
+	l.AddItem("alpha", "", 'a', nil)
+	l.AddItem("beta", "", 'b', nil).
+		AddSubItem("b1", "", 0, true, nil).
+		AddSubItem("b2", "", 0, true, nil)
+	l.AddItem("gamma", "", 'c', nil)
+	return l
+}
+
+// assertPath fails the test if the given path differs from the expected one.
+func assertPath(t *testing.T, name string, got []int, expected ...int) {
+	t.Helper()
+	if !equals(got, expected) {
+		t.Errorf("%s: got %v, expected %v", name, got, expected)
+	}
+}
+
+func TestDeepListUnmarshalTree(t *testing.T) {
+	source := NewDeepList()
+	source.AddHeader("Section")
+	source.AddItem("one", "", 0, nil)
+	data, err := source.MarshalTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := newTestDeepList().EnableHistory(5).SetMultiSelectMode(true)
+	l.SetCurrentItem([]int{2})
+	l.clickSelection([]int{0}, tcell.ModCtrl)
+	l.SetOffset(1, 3)
+	var changes int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		changes++
+	})
+
+	if err := l.UnmarshalTree(data); err != nil {
+		t.Fatal(err)
+	}
+	assertPath(t, "current item", l.GetCurrentItem(), 1)
+	if changes != 1 {
+		t.Errorf("got %d changed events, expected 1", changes)
+	}
+	if vertical, horizontal := l.GetOffset(); vertical != 0 || horizontal != 0 {
+		t.Errorf("got offsets %d, %d, expected 0, 0", vertical, horizontal)
+	}
+	if paths := l.GetSelectedPaths(); len(paths) != 1 || !equals(paths[0], []int{1}) {
+		t.Errorf("got selected paths %v, expected [[1]]", paths)
+	}
+	if !l.Undo() || l.GetItemCount() != 3 {
+		t.Error("UnmarshalTree() could not be undone")
+	}
+}
+
+// pressKey sends a key event to the list.
+func pressKey(l *DeepList, key tcell.Key, r rune, modifiers tcell.ModMask) {
+	l.InputHandler()(tcell.NewEventKey(key, r, modifiers), func(Primitive) {})
+}
+
+func TestDeepListFilterKeepsAncestors(t *testing.T) {
+	l := newTestDeepList()
+	l.AddSubItem("c1", "", 0, false, nil)
+	l.SetCurrentItem([]int{0})
+	l.SetFilter(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return mainText == "b2" || mainText == "c1"
+	})
+
+	// "beta" remains as the ancestor of "b2", "gamma" as the ancestor of the
+	// hidden "c1".
+	var paths [][]int
+	for _, row := range l.visibleRows() {
+		paths = append(paths, row.path)
+	}
+	expected := [][]int{{1}, {1, 1}, {2}}
+	if len(paths) != len(expected) {
+		t.Fatalf("got visible paths %v, expected %v", paths, expected)
+	}
+	for index := range expected {
+		assertPath(t, "visible path", paths[index], expected[index]...)
+	}
+	if count := l.GetVisibleItemCount(); count != 3 {
+		t.Errorf("got %d visible items, expected 3", count)
+	}
+	if count := l.GetItemCount(); count != 3 {
+		t.Errorf("got %d top-level items, expected 3", count)
+	}
+
+	// The filtered out selection snaps to the first shown item.
+	assertPath(t, "current item", l.GetCurrentItem(), 1)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	assertPath(t, "after Down", l.GetCurrentItem(), 1, 1)
+
+	l.SetFilter(nil)
+	if count := l.GetVisibleItemCount(); count != 5 {
+		t.Errorf("got %d visible items without filter, expected 5", count)
+	}
+}
+
+func TestDeepListCountWithFilter(t *testing.T) {
+	l := newTestDeepList()
+	assertCounts := func(name string, items, visible int) {
+		t.Helper()
+		if count := l.GetItemCount(); count != items {
+			t.Errorf("%s: got %d items, expected %d", name, count, items)
+		}
+		if count := l.GetVisibleItemCount(); count != visible {
+			t.Errorf("%s: got %d visible items, expected %d", name, count, visible)
+		}
+		if count := l.GetSiblingCount([]int{1, 0}); count != 2 {
+			t.Errorf("%s: got %d sub items, expected 2", name, count)
+		}
+	}
+	assertCounts("without a filter", 3, 5)
+
+	// Only the visible count follows the filter.
+	l.SetFilter(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return mainText == "b1"
+	})
+	assertCounts("with a filter", 3, 2)
+	l.SetFilter(nil)
+	assertCounts("after clearing the filter", 3, 5)
+}
+
+func TestDeepListWrapAround(t *testing.T) {
+	l := newTestDeepList().SetWrapAround(true)
+	l.AddSubItem("c1", "", 0, true, nil)
+
+	// Up on the first item wraps to the last visible sub item.
+	pressKey(l, tcell.KeyUp, 0, tcell.ModNone)
+	assertPath(t, "after Up", l.GetCurrentItem(), 2, 0)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	assertPath(t, "after Down", l.GetCurrentItem(), 0)
+
+	// Without wrapping, the selection stops at the end.
+	l.SetWrapAround(false)
+	pressKey(l, tcell.KeyUp, 0, tcell.ModNone)
+	assertPath(t, "after Up without wrapping", l.GetCurrentItem(), 0)
+}
+
+func TestDeepListHomeEnd(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false)
+	l.AddHeader("Section")
+	l.AddItem("alpha", "", 0, nil)
+	l.AddItem("beta", "", 0, nil).
+		AddSubItem("b1", "", 0, true, nil).
+		AddSubItem("b2", "", 0, true, nil)
+	l.AddSubSeparator()
+	l.AddItem("gamma", "", 0, nil).AddSubItem("c1", "", 0, true, nil)
+
+	pressKey(l, tcell.KeyEnd, 0, tcell.ModNone)
+	assertPath(t, "after End", l.GetCurrentItem(), 3, 0)
+	pressKey(l, tcell.KeyHome, 0, tcell.ModNone)
+	assertPath(t, "after Home", l.GetCurrentItem(), 1)
+
+	// End skips a trailing separator and stays out of collapsed sublists.
+	l.ToggleSubListDisplay(3)
+	l.AddItem("delta", "", 0, nil).AddSubItem("d1", "", 0, false, nil)
+	l.AddSeparator()
+	pressKey(l, tcell.KeyEnd, 0, tcell.ModNone)
+	assertPath(t, "after End with a separator", l.GetCurrentItem(), 4)
+}
+
+func TestDeepListCollapseMovesSelection(t *testing.T) {
+	l := newTestDeepList()
+	var changes [][]int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		changes = append(changes, append([]int(nil), path...))
+	})
+	l.SetCurrentItem([]int{1, 1})
+	changes = nil
+
+	l.ToggleSubListDisplay(1)
+	assertPath(t, "current item", l.GetCurrentItem(), 1)
+	if len(changes) != 1 || !equals(changes[0], []int{1}) {
+		t.Errorf("got changed events %v, expected [[1]]", changes)
+	}
+}
+
+// drawDeepList draws the list onto a simulation screen of the given size.
+func drawDeepList(l *DeepList, width, height int) tcell.SimulationScreen {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	screen.SetSize(width, height)
+	l.SetRect(0, 0, width, height)
+	l.Draw(screen)
+	screen.Show()
+	return screen
+}
+
+// newLargeTestDeepList returns a list with 500 top-level items, each with a
+// displayed sublist of 99 items, i.e. 50,000 items in total.
+func newLargeTestDeepList() *DeepList {
+	l := NewDeepList().SetScrollbarVisibility(ScrollbarAuto)
+	for index := 0; index < 500; index++ {
+		l.AddItem(fmt.Sprintf("Item %d", index), "Secondary\ttext", 0, nil)
+		for subIndex := 0; subIndex < 99; subIndex++ {
+			l.AddSubItem(fmt.Sprintf("Sub item\t%d.%d", index, subIndex), "", 0, true, nil)
+		}
+	}
+	return l
+}
+
+func BenchmarkDeepListNavigate(b *testing.B) {
+	l := newLargeTestDeepList()
+	screen := drawDeepList(l, 80, 25)
+	l.SetCurrentItem([]int{250, 50})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+		l.Draw(screen)
+	}
+}
+
+func TestDeepListLayoutCache(t *testing.T) {
+	l := newTestDeepList()
+	drawDeepList(l, 10, 5)
+	if height := l.ContentHeight(); height != 5 {
+		t.Fatalf("got content height %d, expected 5", height)
+	}
+
+	// Changes of texts and settings are reflected in the row heights.
+	l.SetItemSecondaryVisible([]int{1, 0}, true)
+	if height := l.ContentHeight(); height != 6 {
+		t.Errorf("got content height %d with a secondary text, expected 6", height)
+	}
+	l.SetItemText(0, "a long main text", "")
+	l.SetWrap(true)
+	if height := l.ContentHeight(); height <= 6 {
+		t.Errorf("got content height %d with wrapping, expected more than 6", height)
+	}
+	l.SetWrap(false).ShowSecondaryText(true)
+	l.SetItemSecondaryLines([]int{2}, []string{"one", "two"})
+	if height := l.ContentHeight(); height != 10 {
+		t.Errorf("got content height %d with secondary texts, expected 10", height)
+	}
+
+	// The shortcut column follows the shortcuts.
+	before := l.shortcutWidth()
+	l.SetItemStringShortcut([]int{0}, "long")
+	if after := l.shortcutWidth(); after <= before {
+		t.Errorf("got shortcut width %d after %d, expected it to grow", after, before)
+	}
+
+	// The current row follows structural changes.
+	l.SetCurrentItem([]int{2})
+	if row := l.currentRowIndex(l.visibleRows()); row != 4 {
+		t.Errorf("got current row %d, expected 4", row)
+	}
+	l.ToggleSubListDisplay(1)
+	if row := l.currentRowIndex(l.visibleRows()); row != 2 {
+		t.Errorf("got current row %d after collapsing, expected 2", row)
+	}
+}
+
+func BenchmarkDeepListDraw(b *testing.B) {
+	l := newLargeTestDeepList()
+	screen := drawDeepList(l, 80, 25)
+	l.SetCurrentItem([]int{250, 50})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Draw(screen)
+	}
+}
+
+// deepListScreen returns the runes of the given screen, one line per row.
+func deepListScreen(screen tcell.SimulationScreen) []string {
+	cells, width, height := screen.GetContents()
+	lines := make([]string, height)
+	for y := 0; y < height; y++ {
+		var line strings.Builder
+		for x := 0; x < width; x++ {
+			if cell := cells[y*width+x]; len(cell.Runes) > 0 {
+				line.WriteRune(cell.Runes[0])
+			} else {
+				line.WriteRune(' ')
+			}
+		}
+		lines[y] = line.String()
+	}
+	return lines
+}
+
+func TestDeepListItemSecondaryVisible(t *testing.T) {
+	l := NewDeepList()
+	l.AddItem("alpha", "A", 0, nil)
+	l.AddItem("beta", "B", 0, nil).AddSubItem("b1", "B1", 0, true, nil)
+	l.SetItemSecondaryVisible([]int{0}, false).
+		SetItemSecondaryVisible([]int{1, 0}, true)
+
+	lines := deepListScreen(drawDeepList(l, 10, 6))
+	expected := []string{"alpha", "beta", "B", "b1", "B1", ""}
+	for index, line := range lines {
+		if strings.TrimSpace(line) != expected[index] {
+			t.Errorf("row %d: got %q, expected %q", index, strings.TrimSpace(line), expected[index])
+		}
+	}
+	if path := l.indexAtPoint(3, 4); !equals(path, []int{1, 0}) {
+		t.Errorf("got path %v for the secondary text of b1, expected [1 0]", path)
+	}
+
+	// The override keeps the selection in view.
+	drawDeepList(l, 10, 4)
+	l.SetCurrentItem([]int{1, 0})
+	if offset, _ := l.GetOffset(); offset != 1 {
+		t.Errorf("got offset %d, expected 1", offset)
+	}
+}
+
+func TestDeepListMarshalTree(t *testing.T) {
+	l := newTestDeepList()
+	l.AddSeparator()
+	l.AddHeader("Section")
+	l.AddItem("delta", "", 0, nil)
+	l.SetItemPrefix([]int{5}, "> ").
+		SetItemIcon([]int{5}, '*', tcell.StyleDefault).
+		SetItemTrailingText([]int{5}, "new", tcell.StyleDefault).
+		SetItemHelp([]int{5}, "help").
+		SetItemStringShortcut([]int{5}, "gd")
+
+	data, err := l.MarshalTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewDeepList()
+	if err := restored.UnmarshalTree(data); err != nil {
+		t.Fatal(err)
+	}
+	if count := restored.GetItemCount(); count != 6 {
+		t.Fatalf("got %d items, expected 6", count)
+	}
+	if item := restored.items[3]; !item.separator {
+		t.Error("separator was not restored")
+	}
+	if item := restored.items[4]; !item.header || item.MainText != "Section" {
+		t.Errorf("header was not restored: %+v", item)
+	}
+	item := restored.items[5]
+	if item.prefix != "> " || item.icon != '*' || item.trailingText != "new" || item.help != "help" || item.stringShortcut != "gd" {
+		t.Errorf("item decorations were not restored: %+v", item)
+	}
+	if sub := restored.items[1].SubList; sub == nil || !sub.display || len(sub.items) != 2 {
+		t.Errorf("sublist was not restored: %+v", sub)
+	}
+}
+
+func TestDeepListArrowsSkipSeparators(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false).SetWrapAround(false)
+	l.AddItem("a", "", 0, nil)
+	l.AddSeparator()
+	l.AddItem("b", "", 0, nil).
+		AddSubItem("b1", "", 0, true, nil).
+		AddSubSeparator().
+		AddSubItem("b2", "", 0, true, nil)
+	l.AddSeparator()
+
+	for _, step := range []struct {
+		key      tcell.Key
+		expected []int
+	}{
+		{tcell.KeyDown, []int{2}},
+		{tcell.KeyDown, []int{2, 0}},
+		{tcell.KeyDown, []int{2, 2}},
+		{tcell.KeyDown, []int{2, 2}},
+		{tcell.KeyUp, []int{2, 0}},
+		{tcell.KeyUp, []int{2}},
+		{tcell.KeyUp, []int{0}},
+		{tcell.KeyUp, []int{0}},
+	} {
+		pressKey(l, step.key, 0, tcell.ModNone)
+		assertPath(t, "without wrapping, "+tcell.KeyNames[step.key], l.GetCurrentItem(), step.expected...)
+	}
+
+	// With wrapping, the trailing separator is skipped, too.
+	l.SetWrapAround(true)
+	pressKey(l, tcell.KeyUp, 0, tcell.ModNone)
+	assertPath(t, "wrapped Up", l.GetCurrentItem(), 2, 2)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	assertPath(t, "wrapped Down", l.GetCurrentItem(), 0)
+}
+
+func TestDeepListStickyHeaderOffset(t *testing.T) {
+	// Without a header, no row is reserved.
+	l := NewDeepList().ShowSecondaryText(false).SetStickyHeaders(true)
+	for _, text := range []string{"a", "b", "c", "d", "e"} {
+		l.AddItem(text, "", 0, nil)
+	}
+	drawDeepList(l, 10, 3)
+	l.SetCurrentItem([]int{2})
+	if offset, _ := l.GetOffset(); offset != 0 {
+		t.Errorf("got offset %d without headers, expected 0", offset)
+	}
+
+	// With a header, the selection is kept below it.
+	l = NewDeepList().ShowSecondaryText(false).SetStickyHeaders(true)
+	l.AddHeader("H")
+	for _, text := range []string{"a", "b", "c", "d"} {
+		l.AddItem(text, "", 0, nil)
+	}
+	drawDeepList(l, 10, 3)
+	l.SetCurrentItem([]int{4})
+	screen := drawDeepList(l, 10, 3)
+	lines := deepListScreen(screen)
+	if strings.TrimSpace(lines[0]) != "H" || strings.TrimSpace(lines[2]) != "d" {
+		t.Errorf("unexpected screen %q", lines)
+	}
+}
+
+func TestDeepListAddHeaderPath(t *testing.T) {
+	l := newTestDeepList()
+	l.AddHeaderPath([]int{1, 0}, "Deep")
+	item := l.items[1].SubList.items[0]
+	if item.SubList == nil || len(item.SubList.items) != 1 || !item.SubList.items[0].header {
+		t.Fatalf("header was not added: %+v", item.SubList)
+	}
+	if item.SubList.display {
+		t.Error("new sublist is displayed")
+	}
+	l.AddHeaderPath([]int{9}, "None")
+	if l.GetItemCount() != 3 {
+		t.Error("header was added for an invalid path")
+	}
+}
+
+func TestDeepListHoverDoesNotCapture(t *testing.T) {
+	l := newTestDeepList().
+		SetMouseHover(true).
+		SetHoverStyle(tcell.StyleDefault.Background(tcell.ColorBlue))
+	drawDeepList(l, 20, 5)
+	hoverBackground := func(screen tcell.SimulationScreen) tcell.Color {
+		_, _, style, _ := screen.GetContent(1, 2)
+		_, bg, _ := style.Decompose()
+		return bg
+	}
+
+	// Moving the mouse over "b1" highlights it without capturing the mouse,
+	// so clicks elsewhere reach other primitives.
+	consumed, capture := l.MouseHandler()(MouseMove, tcell.NewEventMouse(1, 2, tcell.ButtonNone, tcell.ModNone), func(Primitive) {})
+	if !consumed || capture != nil {
+		t.Errorf("got consumed %t and capture %v, expected true and nil", consumed, capture)
+	}
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	screen.SetSize(20, 5)
+	l.Draw(screen)
+	if bg := hoverBackground(screen); bg != tcell.ColorBlue {
+		t.Errorf("got background %v, expected the hover background", bg)
+	}
+
+	// Without another mouse event, the next draw removes the highlight.
+	l.Draw(screen)
+	if bg := hoverBackground(screen); bg == tcell.ColorBlue {
+		t.Error("hover highlight remained after the mouse left")
+	}
+}
+
+func TestDeepListTreeNavigationSkipsHeaders(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false).SetTreeNavigation(true)
+	l.AddItem("a", "", 0, nil).
+		AddSubHeader("H").
+		AddSubItem("a1", "", 0, true, nil)
+	l.AddItem("b", "", 0, nil).
+		AddSubSeparator().
+		AddSubItem("b1", "", 0, false, nil)
+	l.AddHeader("Section").
+		AddSubItem("s1", "", 0, true, nil)
+
+	// Right skips a header or a separator as the first child.
+	l.SetCurrentItem([]int{0})
+	pressKey(l, tcell.KeyRight, 0, tcell.ModNone)
+	assertPath(t, "Right on an expanded parent", l.GetCurrentItem(), 0, 1)
+	l.SetSelectChildOnExpand(true).SetCurrentItem([]int{1})
+	pressKey(l, tcell.KeyRight, 0, tcell.ModNone)
+	assertPath(t, "Right on a collapsed parent", l.GetCurrentItem(), 1, 1)
+	l.SetCurrentItem([]int{1})
+	pressKey(l, tcell.KeyRight, 0, tcell.ModNone)
+	assertPath(t, "Right on an expanded parent", l.GetCurrentItem(), 1, 1)
+
+	// Neither Left nor the parent key select a header parent.
+	l.SetCurrentItem([]int{2, 0})
+	pressKey(l, tcell.KeyBackspace2, 0, tcell.ModNone)
+	assertPath(t, "parent key below a header", l.GetCurrentItem(), 2, 0)
+	pressKey(l, tcell.KeyLeft, 0, tcell.ModNone)
+	assertPath(t, "Left below a header", l.GetCurrentItem(), 1, 1)
+	pressKey(l, tcell.KeyLeft, 0, tcell.ModNone)
+	assertPath(t, "Left on a sub item", l.GetCurrentItem(), 1)
+}
+
+func TestDeepListRightClick(t *testing.T) {
+	l := newTestDeepList()
+	drawDeepList(l, 20, 8)
+	consumed, _ := l.MouseHandler()(MouseRightClick, tcell.NewEventMouse(3, 1, tcell.Button2, tcell.ModNone), func(Primitive) {})
+	if consumed {
+		t.Error("right click without a handler was consumed")
+	}
+
+	var (
+		clicked        []int
+		clickX, clickY int
+		calls          int
+	)
+	l.SetRightClickFunc(func(path []int, x, y int) {
+		clicked, clickX, clickY = path, x, y
+		calls++
+	})
+	consumed, _ = l.MouseHandler()(MouseRightClick, tcell.NewEventMouse(3, 2, tcell.Button2, tcell.ModNone), func(Primitive) {})
+	if !consumed || calls != 1 {
+		t.Fatalf("got consumed %t and %d calls, expected true and 1", consumed, calls)
+	}
+	assertPath(t, "right-clicked path", clicked, 1, 0)
+	if clickX != 3 || clickY != 2 {
+		t.Errorf("got position %d, %d, expected 3, 2", clickX, clickY)
+	}
+	assertPath(t, "current item", l.GetCurrentItem(), 0)
+
+	// The empty area below the items.
+	l.MouseHandler()(MouseRightClick, tcell.NewEventMouse(3, 7, tcell.Button2, tcell.ModNone), func(Primitive) {})
+	if calls != 2 || clicked != nil {
+		t.Errorf("got path %v in %d calls for the empty area, expected nil in 2", clicked, calls)
+	}
+}
+
+func TestDeepListSelectChildOnExpand(t *testing.T) {
+	l := newTestDeepList().SetSelectChildOnExpand(true)
+	l.ToggleSubListDisplay(1)
+	l.SetCurrentItem([]int{1})
+	var changes [][]int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		changes = append(changes, path)
+	})
+
+	l.ToggleSubListDisplay(1)
+	assertPath(t, "after expanding", l.GetCurrentItem(), 1, 0)
+	if len(changes) != 1 {
+		t.Errorf("got %d changed events, expected 1", len(changes))
+	}
+
+	// Collapsing moves the selection back to the parent.
+	l.ToggleSubListDisplay(1)
+	assertPath(t, "after collapsing", l.GetCurrentItem(), 1)
+
+	// The Right key with tree navigation expands and selects the first child.
+	l.SetTreeNavigation(true)
+	pressKey(l, tcell.KeyRight, 0, tcell.ModNone)
+	assertPath(t, "after Right", l.GetCurrentItem(), 1, 0)
+	if len(changes) != 3 {
+		t.Errorf("got %d changed events in total, expected 3", len(changes))
+	}
+
+	// Without the flag, expanding keeps the selection.
+	l.SetSelectChildOnExpand(false).SetCurrentItem([]int{1})
+	l.ToggleSubListDisplay(1)
+	l.ToggleSubListDisplay(1)
+	assertPath(t, "without the flag", l.GetCurrentItem(), 1)
+}
+
+func TestDeepListSiblingKeys(t *testing.T) {
+	l := newTestDeepList().
+		SetSiblingKeys(tcell.KeyDown, tcell.KeyUp, tcell.ModCtrl).
+		SetWrapAround(false)
+	l.AddSeparator()
+	l.AddItem("delta", "", 0, nil)
+
+	// The displayed sub items of "beta" are skipped.
+	l.SetCurrentItem([]int{1})
+	pressKey(l, tcell.KeyDown, 0, tcell.ModCtrl)
+	assertPath(t, "next sibling", l.GetCurrentItem(), 2)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModCtrl)
+	assertPath(t, "next sibling after the separator", l.GetCurrentItem(), 4)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModCtrl)
+	assertPath(t, "last sibling", l.GetCurrentItem(), 4)
+
+	// Sub items stay within their list, with wrapping.
+	l.SetCurrentItem([]int{1, 1})
+	pressKey(l, tcell.KeyDown, 0, tcell.ModCtrl)
+	assertPath(t, "last sub item", l.GetCurrentItem(), 1, 1)
+	l.SetWrapAround(true)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModCtrl)
+	assertPath(t, "wrapped sub item", l.GetCurrentItem(), 1, 0)
+	pressKey(l, tcell.KeyUp, 0, tcell.ModCtrl)
+	assertPath(t, "wrapped back", l.GetCurrentItem(), 1, 1)
+
+	// Without the modifier, the keys move as usual.
+	pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	assertPath(t, "plain Down", l.GetCurrentItem(), 2)
+}
+
+func TestDeepListWideShortcut(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false)
+	l.AddItem("alpha", "", '漢', nil)
+	l.AddItem("beta", "", 'b', nil)
+	lines := deepListScreen(drawDeepList(l, 20, 2))
+
+	// The column fits "(漢)", which is four cells wide, and a gap.
+	for index, expected := range []string{"alpha", "beta"} {
+		if text := string([]rune(lines[index])[5 : 5+len(expected)]); text != expected {
+			t.Errorf("row %d: got %q at column 5, expected %q", index, text, expected)
+		}
+	}
+	if shortcut := string([]rune(lines[0])[:2]); shortcut != "(漢" {
+		t.Errorf("got shortcut %q, expected it to start with \"(漢\"", shortcut)
+	}
+}
+
+func TestDeepListSubItemHighlight(t *testing.T) {
+	l := NewDeepList().
+		ShowSecondaryText(false).
+		SetMainTextStyle(tcell.StyleDefault.Foreground(tcell.ColorWhite)).
+		SetSecondaryTextStyle(tcell.StyleDefault.Foreground(tcell.ColorYellow)).
+		SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGreen))
+	l.AddItem("top", "", 0, nil).
+		AddSubItem("x[red]y", "", 0, true, nil)
+	l.SetCurrentItem([]int{0, 0})
+	screen := drawDeepList(l, 20, 2)
+
+	// Sub items are drawn in the secondary text style, so their plain
+	// characters take the selected color and tagged ones keep theirs.
+	column := -1
+	for x, r := range []rune(deepListScreen(screen)[1]) {
+		if r == 'x' {
+			column = x
+			break
+		}
+	}
+	if column < 0 {
+		t.Fatal("sub item not drawn")
+	}
+	for offset, expected := range []tcell.Color{tcell.ColorBlack, tcell.ColorRed} {
+		_, _, style, _ := screen.GetContent(column+offset, 1)
+		if fg, bg, _ := style.Decompose(); fg != expected || bg != tcell.ColorGreen {
+			t.Errorf("column %d: got colors %v on %v, expected %v on green", column+offset, fg, bg, expected)
+		}
+	}
+}
+
+func TestDeepListSelectRespectsMaxVisibleDepth(t *testing.T) {
+	l := newTestDeepList().SetMaxVisibleDepth(1)
+	if !l.SelectByText("b2", true) {
+		t.Fatal("b2 was not found")
+	}
+	assertPath(t, "SelectByText", l.GetCurrentItem(), 1)
+
+	l.SetCurrentItem([]int{0})
+	if !l.SelectByKey("beta/b1") {
+		t.Fatal("beta/b1 was not found")
+	}
+	assertPath(t, "SelectByKey", l.GetCurrentItem(), 1)
+}
+
+// newNestedTestDeepList returns a list with the items "a" (with the sub items
+// "a1", which has the sub items "a1x" and "a1y", and "a2"), "b", "c" (with the
+// sub item "c1"), and "d". All sublists are displayed.
+func newNestedTestDeepList(t *testing.T) *DeepList {
+	t.Helper()
+	l := NewDeepList().ShowSecondaryText(false)
+	err := l.UnmarshalTree([]byte(`[
+		{"mainText": "a", "display": true, "items": [
+			{"mainText": "a1", "display": true, "items": [
+				{"mainText": "a1x"},
+				{"mainText": "a1y"}
+			]},
+			{"mainText": "a2"}
+		]},
+		{"mainText": "b"},
+		{"mainText": "c", "display": true, "items": [
+			{"mainText": "c1"}
+		]},
+		{"mainText": "d"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestDeepListPageKeys(t *testing.T) {
+	l := newNestedTestDeepList(t).SetWrapAround(false)
+	drawDeepList(l, 20, 4)
+
+	// Pages span the visible rows of all levels.
+	for _, step := range []struct {
+		key      tcell.Key
+		expected []int
+	}{
+		{tcell.KeyPgDn, []int{0, 1}},
+		{tcell.KeyPgDn, []int{3}},
+		{tcell.KeyPgDn, []int{3}},
+		{tcell.KeyPgUp, []int{0, 1}},
+		{tcell.KeyPgUp, []int{0}},
+	} {
+		pressKey(l, step.key, 0, tcell.ModNone)
+		assertPath(t, tcell.KeyNames[step.key], l.GetCurrentItem(), step.expected...)
+	}
+
+	// A collapsed sublist does not count.
+	l.ToggleSubListDisplayPath([]int{0, 0}).SetCurrentItem([]int{0, 0})
+	pressKey(l, tcell.KeyPgDn, 0, tcell.ModNone)
+	assertPath(t, "page over a collapsed sublist", l.GetCurrentItem(), 2, 0)
+
+	// Items with a secondary text take two rows, so a page holds two of them.
+	l = NewDeepList()
+	for index := 0; index < 10; index++ {
+		l.AddItem(fmt.Sprintf("item %d", index), "secondary", 0, nil)
+	}
+	drawDeepList(l, 20, 4)
+	for _, step := range []struct {
+		key      tcell.Key
+		expected int
+	}{
+		{tcell.KeyPgDn, 2},
+		{tcell.KeyPgDn, 4},
+		{tcell.KeyPgUp, 2},
+	} {
+		pressKey(l, step.key, 0, tcell.ModNone)
+		assertPath(t, "with secondary texts, "+tcell.KeyNames[step.key], l.GetCurrentItem(), step.expected)
+	}
+
+	// An item taller than a page is left with the next key press.
+	l.SetItemTextPath([]int{2}, "item 2", "one\ntwo\nthree\nfour")
+	pressKey(l, tcell.KeyPgDn, 0, tcell.ModNone)
+	assertPath(t, "from a tall item", l.GetCurrentItem(), 3)
+}
+
+func TestDeepListMouseConsumed(t *testing.T) {
+	l := newTestDeepList()
+	drawDeepList(l, 20, 3)
+	for _, event := range []struct {
+		name     string
+		action   MouseAction
+		x, y     int
+		consumed bool
+	}{
+		{"click", MouseLeftClick, 2, 1, true},
+		{"double click", MouseLeftDoubleClick, 2, 1, true},
+		{"scroll down", MouseScrollDown, 2, 0, true},
+		{"scroll up", MouseScrollUp, 2, 0, true},
+		{"right click without a handler", MouseRightClick, 2, 0, false},
+		{"move", MouseMove, 2, 0, false},
+		{"click outside", MouseLeftClick, 25, 1, false},
+		{"scroll outside", MouseScrollDown, 2, 5, false},
+	} {
+		consumed, _ := l.MouseHandler()(event.action, tcell.NewEventMouse(event.x, event.y, tcell.Button1, tcell.ModNone), func(Primitive) {})
+		if consumed != event.consumed {
+			t.Errorf("%s: got consumed %t, expected %t", event.name, consumed, event.consumed)
+		}
+	}
+	assertPath(t, "after the clicks", l.GetCurrentItem(), 1)
+}
+
+// newTestRemovalList returns a list with the top-level items "a", "b" (with the
+// sub items "b0" to "b4" where "b1" is a separator and "b3" a header), and "c".
+func newTestRemovalList() *DeepList {
+	l := NewDeepList().ShowSecondaryText(false)
+	l.AddItem("a", "", 0, nil)
+	l.AddItem("b", "", 0, nil).
+		AddSubItem("b0", "", 0, true, nil).
+		AddSubSeparator().
+		AddSubItem("b2", "", 0, true, nil).
+		AddSubHeader("b3").
+		AddSubItem("b4", "", 0, true, nil)
+	l.AddItem("c", "", 0, nil)
+	return l
+}
+
+func TestDeepListRemoveItemFallback(t *testing.T) {
+	// The next sibling, skipping the header.
+	l := newTestRemovalList().SetCurrentItem([]int{1, 2})
+	l.RemoveItem([]int{1, 2})
+	assertPath(t, "next sibling", l.GetCurrentItem(), 1, 3) // Formerly "b4".
+
+	// The previous sibling, skipping the separator.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 2})
+	l.RemoveItem([]int{1, 4})
+	l.RemoveItem([]int{1, 3})
+	l.RemoveItem([]int{1, 2})
+	assertPath(t, "previous sibling", l.GetCurrentItem(), 1, 0)
+
+	// The parent if only a separator is left.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 0})
+	l.RemoveItem([]int{1, 4})
+	l.RemoveItem([]int{1, 3})
+	l.RemoveItem([]int{1, 2})
+	l.RemoveItem([]int{1, 0})
+	assertPath(t, "parent", l.GetCurrentItem(), 1)
+
+	// Removing an ancestor of the selection.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 4})
+	l.RemoveItem([]int{1})
+	assertPath(t, "ancestor", l.GetCurrentItem(), 1) // Formerly "c".
+
+	// An empty list, without a "changed" event.
+	l = NewDeepList()
+	l.AddItem("a", "", 0, nil)
+	var changes int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		changes++
+	})
+	l.RemoveItem([]int{0})
+	assertPath(t, "empty list", l.GetCurrentItem(), 0)
+	if changes != 0 {
+		t.Errorf("got %d changed events for an empty list, expected 0", changes)
+	}
+}
+
+func TestDeepListRemoveItemsFuncFallback(t *testing.T) {
+	// An interior node with children.
+	l := newTestRemovalList().SetCurrentItem([]int{1, 2})
+	count := l.RemoveItemsFunc(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return mainText == "b"
+	})
+	if count != 6 {
+		t.Errorf("got %d removed items, expected 6", count)
+	}
+	assertPath(t, "interior node", l.GetCurrentItem(), 1) // Formerly "c".
+
+	// The next sibling, skipping the header.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 2})
+	l.RemoveItemsFunc(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return mainText == "b2"
+	})
+	assertPath(t, "next sibling", l.GetCurrentItem(), 1, 3)
+
+	// The previous sibling, skipping the separator.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 2})
+	l.RemoveItemsFunc(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return mainText == "b2" || mainText == "b4"
+	})
+	assertPath(t, "previous sibling", l.GetCurrentItem(), 1, 0)
+
+	// The parent.
+	l = newTestRemovalList().SetCurrentItem([]int{1, 2})
+	l.RemoveItemsFunc(func(path []int, mainText, secondaryText string, shortcut rune) bool {
+		return len(path) == 2 && mainText != "b3"
+	})
+	assertPath(t, "parent", l.GetCurrentItem(), 1)
+}
+
+func TestDeepListAutoAssignShortcuts(t *testing.T) {
+	l := NewDeepList()
+	l.AddItem("Apple", "", 'A', nil)
+	l.AddItem("apricot", "", 0, nil)
+	l.AddItem("[red]banana", "", 0, nil)
+	l.AddItem("bab", "", 0, nil)
+	l.AddSeparator()
+	l.AddItem("b", "", 0, nil)
+	l.AddItem("gd", "", 0, nil).SetItemStringShortcut([]int{6}, "gd")
+	l.AddItem("Pear", "", 0, nil)
+	l.AutoAssignShortcuts()
+
+	// "A" blocks "a", color tags are ignored, and items whose letters are
+	// all taken receive digits.
+	for index, expected := range []rune{'A', 'p', 'b', '1', 0, '2', 0, 'e'} {
+		if shortcut := l.items[index].Shortcut; shortcut != expected {
+			t.Errorf("item %d: got shortcut %q, expected %q", index, shortcut, expected)
+		}
+	}
+}
+
+func TestDeepListClone(t *testing.T) {
+	l := newTestDeepList()
+	l.SetCurrentItem([]int{1, 0})
+	mainStyle := l.mainTextStyle
+	clone := l.Clone()
+
+	clone.SetItemTextPath([]int{1, 0}, "changed", "")
+	clone.ToggleSubListDisplay(1)
+	clone.AddItem("delta", "", 'd', nil)
+	clone.SetCurrentItem([]int{3})
+	clone.SetMainTextStyle(mainStyle.Foreground(tcell.ColorRed))
+	clone.SetBorder(true)
+
+	if main, _, _ := l.GetItemTextPath([]int{1, 0}); main != "b1" {
+		t.Errorf("got text %q, expected \"b1\"", main)
+	}
+	if !l.items[1].SubList.display {
+		t.Error("sublist of the original was hidden")
+	}
+	if count := l.GetItemCount(); count != 3 {
+		t.Errorf("got %d items, expected 3", count)
+	}
+	assertPath(t, "original selection", l.GetCurrentItem(), 1, 0)
+	if l.mainTextStyle != mainStyle {
+		t.Error("main text style of the original was changed")
+	}
+	if l.border {
+		t.Error("original got a border")
+	}
+	if lines := deepListScreen(drawDeepList(l, 10, 5)); !strings.Contains(lines[2], "b1") {
+		t.Errorf("original draws %q, expected \"b1\" in the third line", lines)
+	}
+}
+
+func TestDeepListRemoveInCallback(t *testing.T) {
+	l := newTestRemovalList().SetCurrentItem([]int{2})
+	l.SetSelectedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		l.RemoveItem(path)
+	})
+	pressKey(l, tcell.KeyEnter, 0, tcell.ModNone)
+	assertPath(t, "after removal", l.GetCurrentItem(), 1)
+	pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	drawDeepList(l, 20, 5)
+}
+
+func TestDeepListNormalizeCurrentItem(t *testing.T) {
+	l := newTestRemovalList()
+	l.currentItem = []int{1, 9}
+	if !l.normalizeCurrentItem() {
+		t.Error("change was not reported")
+	}
+	assertPath(t, "clamped", l.currentItem, 1, 4)
+
+	// A clamped path which ends on a header moves to a selectable sibling.
+	l.RemoveItem([]int{1, 4})
+	l.currentItem = []int{1, 9}
+	l.normalizeCurrentItem()
+	assertPath(t, "clamped onto header", l.currentItem, 1, 2)
+
+	// An ancestor which no longer exists.
+	l.currentItem = []int{5, 3, 1}
+	l.normalizeCurrentItem()
+	assertPath(t, "truncated", l.currentItem, 2)
+}
+
+func TestDeepListExpandedTabs(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false).SetTabSize(4)
+	l.AddItem("a\tb", "", 0, nil)
+	lines := deepListScreen(drawDeepList(l, 10, 1))
+	if lines[0] != "a   b     " {
+		t.Errorf("got %q with a tab size of 4", lines[0])
+	}
+
+	l.SetTabSize(2)
+	lines = deepListScreen(drawDeepList(l, 10, 1))
+	if lines[0] != "a b       " {
+		t.Errorf("got %q with a tab size of 2", lines[0])
+	}
+
+	l.SetItemText(0, "ab\tc", "")
+	lines = deepListScreen(drawDeepList(l, 10, 1))
+	if lines[0] != "ab  c     " {
+		t.Errorf("got %q after changing the text", lines[0])
+	}
+}
+
+func TestDeepListFocusScrollsIntoView(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false)
+	for index := 0; index < 20; index++ {
+		l.AddItem(fmt.Sprintf("item %d", index), "", 0, nil)
+	}
+	l.SetCurrentItem([]int{15})
+	drawDeepList(l, 20, 10)
+	l.Blur()
+
+	// Shrinking the blurred list leaves the selection out of view.
+	l.SetRect(0, 0, 20, 3)
+	if offset, _ := l.GetOffset(); offset+3 > 15 {
+		t.Fatalf("got offset %d, expected item 15 to be out of view", offset)
+	}
+
+	l.Focus(func(Primitive) {})
+	if offset, _ := l.GetOffset(); offset > 15 || offset+3 <= 15 {
+		t.Errorf("got offset %d, expected item 15 to be visible in three rows", offset)
+	}
+}
+
+// clickDeepList sends a mouse action at the given position to the list.
+func clickDeepList(l *DeepList, action MouseAction, x, y int) {
+	l.MouseHandler()(action, tcell.NewEventMouse(x, y, tcell.Button1, tcell.ModNone), func(Primitive) {})
+}
+
+func TestDeepListIndexAtPointBelowSublist(t *testing.T) {
+	l := NewDeepList()
+	l.AddItem("alpha", "A", 0, nil)
+	l.AddItem("beta", "B", 0, nil).
+		AddSubItem("b1", "", 0, true, nil).
+		AddSubItem("b2", "", 0, true, nil)
+	l.AddItem("gamma", "C", 0, nil)
+	l.SetItemSecondaryVisible([]int{1, 0}, true).
+		SetItemSecondaryLines([]int{1, 0}, []string{"x", "y"})
+	drawDeepList(l, 20, 12)
+
+	for y, expected := range [][]int{{0}, {0}, {1}, {1}, {1, 0}, {1, 0}, {1, 0}, {1, 1}, {2}, {2}, nil} {
+		if path := l.indexAtPoint(5, y); !equals(path, expected) {
+			t.Errorf("got path %v at row %d, expected %v", path, y, expected)
+		}
+	}
+
+	clickDeepList(l, MouseLeftClick, 5, 9)
+	assertPath(t, "clicked item", l.GetCurrentItem(), 2)
+	clickDeepList(l, MouseLeftClick, 5, 7)
+	assertPath(t, "clicked sub item", l.GetCurrentItem(), 1, 1)
+}
+
+func TestDeepListDoubleClickFiresSelected(t *testing.T) {
+	l := newTestDeepList()
+	var selected [][]int
+	l.SetSelectedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		selected = append(selected, path)
+	})
+	drawDeepList(l, 20, 5)
+
+	// "gamma" has no callback of its own.
+	clickDeepList(l, MouseLeftClick, 5, 4)
+	clickDeepList(l, MouseLeftDoubleClick, 5, 4)
+	if len(selected) != 1 {
+		t.Fatalf("got %d selected events, expected 1", len(selected))
+	}
+	assertPath(t, "selected path", selected[0], 2)
+}
+
+func TestDeepListClickFiresChangedOnce(t *testing.T) {
+	l := newTestDeepList()
+	var changes [][]int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		changes = append(changes, path)
+	})
+	drawDeepList(l, 20, 5)
+
+	clickDeepList(l, MouseLeftClick, 5, 3) // "b2"
+	if len(changes) != 1 {
+		t.Fatalf("got %d changed events, expected 1", len(changes))
+	}
+	assertPath(t, "changed path", changes[0], 1, 1)
+
+	// Clicking the selected item again doesn't change anything.
+	clickDeepList(l, MouseLeftClick, 5, 3)
+	if len(changes) != 1 {
+		t.Errorf("got %d changed events after the second click, expected 1", len(changes))
+	}
+}
+
+func TestDeepListEquals(t *testing.T) {
+	for _, test := range []struct {
+		a, b     []int
+		expected bool
+	}{
+		{[]int{0}, []int{0, 1}, false},
+		{[]int{0, 1}, []int{0}, false},
+		{nil, []int{}, true},
+		{nil, []int{0}, false},
+		{[]int{}, nil, true},
+		{[]int{1, 2, 3}, []int{1, 2, 3}, true},
+		{[]int{1, 2, 3}, []int{1, 2, 4}, false},
+	} {
+		if result := equals(test.a, test.b); result != test.expected {
+			t.Errorf("equals(%v, %v) = %t, expected %t", test.a, test.b, result, test.expected)
+		}
+	}
+}
+
+func TestDeepListChangedPathIsCopy(t *testing.T) {
+	l := newTestDeepList()
+	var saved, savedEx []int
+	l.SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {
+		saved = path
+	})
+	l.SetChangedFuncEx(func(previous, current []int, mainText, secondaryText string, shortcut rune) {
+		savedEx = current
+	})
+	l.SetCurrentItem([]int{2})
+	l.InsertItem(0, "first", "", 0, nil)
+	assertPath(t, "current item", l.GetCurrentItem(), 3)
+	assertPath(t, "saved path", saved, 2)
+	assertPath(t, "saved path (Ex)", savedEx, 2)
+}
+
+func TestDeepListConcurrentSetters(t *testing.T) {
+	l := newTestDeepList()
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	screen.SetSize(20, 10)
+	l.SetRect(0, 0, 20, 10)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			l.ShowSecondaryText(i%2 == 0).
+				SetWrap(i%2 == 1).
+				SetHorizontalScrollStep(i%5 + 1).
+				SetMainTextStyle(tcell.StyleDefault).
+				SetSelectedStyle(tcell.StyleDefault.Reverse(true)).
+				SetChangedFunc(func(path []int, mainText, secondaryText string, shortcut rune) {})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		l.Draw(screen)
+		pressKey(l, tcell.KeyDown, 0, tcell.ModNone)
+	}
+	<-done
+}
+
+func TestDeepListFollow(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false)
+	for index := 0; index < 10; index++ {
+		l.AddItem(fmt.Sprintf("item %d", index), "", 0, nil)
+	}
+	l.SetRect(0, 0, 20, 5)
+	l.SetFollow(true)
+	assertOffset := func(name string, expected int) {
+		t.Helper()
+		if offset, _ := l.GetOffset(); offset != expected {
+			t.Errorf("%s: got offset %d, expected %d", name, offset, expected)
+		}
+	}
+	assertOffset("enabled", 5)
+	l.AddItem("item 10", "", 0, nil)
+	assertOffset("appended", 6)
+
+	// Scrolling up suspends following.
+	clickDeepList(l, MouseScrollUp, 2, 2)
+	assertOffset("scrolled up", 5)
+	l.AddItem("item 11", "", 0, nil)
+	assertOffset("appended while scrolled up", 5)
+
+	// Scrolling back to the end resumes it.
+	clickDeepList(l, MouseScrollDown, 2, 2)
+	clickDeepList(l, MouseScrollDown, 2, 2)
+	assertOffset("scrolled back", 7)
+	l.AddItem("item 12", "", 0, nil)
+	assertOffset("appended at the end", 8)
+	if lines := deepListScreen(drawDeepList(l, 20, 5)); strings.TrimSpace(lines[4]) != "item 12" {
+		t.Errorf("got last line %q, expected \"item 12\"", lines[4])
+	}
+}
+
+func TestDeepListShiftArrows(t *testing.T) {
+	l := newTestDeepList().SetMultiSelectMode(true)
+	var reported [][][]int
+	l.SetSelectionChangedFunc(func(paths [][]int) {
+		reported = append(reported, paths)
+	})
+	for _, step := range []struct {
+		name      string
+		key       tcell.Key
+		modifiers tcell.ModMask
+		expected  [][]int
+	}{
+		{"Shift+Down", tcell.KeyDown, tcell.ModShift, [][]int{{0}, {1}}},
+		{"second Shift+Down", tcell.KeyDown, tcell.ModShift, [][]int{{0}, {1}, {1, 0}}},
+		{"Shift+Up", tcell.KeyUp, tcell.ModShift, [][]int{{0}, {1}}},
+		{"Down", tcell.KeyDown, tcell.ModNone, [][]int{{1, 0}}},
+		{"Up", tcell.KeyUp, tcell.ModNone, [][]int{{1}}},
+	} {
+		pressKey(l, step.key, 0, step.modifiers)
+		if got := fmt.Sprint(l.GetSelectedPaths()); got != fmt.Sprint(step.expected) {
+			t.Errorf("%s: got selection %s, expected %v", step.name, got, step.expected)
+		}
+		if len(reported) == 0 || fmt.Sprint(reported[len(reported)-1]) != fmt.Sprint(step.expected) {
+			t.Errorf("%s: got reported selections %v, expected %v last", step.name, reported, step.expected)
+		}
+	}
+	if len(reported) != 5 {
+		t.Errorf("got %d selection changes, expected 5", len(reported))
+	}
+}
+
+func TestDeepListCollapseResetsDescendants(t *testing.T) {
+	l := newNestedTestDeepList(t).SetCollapseResetsDescendants(true)
+	var expanded []string
+	l.SetExpandedFunc(func(path []int, isExpanded bool) {
+		expanded = append(expanded, fmt.Sprint(path, isExpanded))
+	})
+
+	// Only the direct sub items reappear after a collapse/expand cycle.
+	l.ToggleSubListDisplayPath([]int{0})
+	if a1 := l.items[0].SubList.items[0]; a1.SubList.display {
+		t.Error("sublist of \"a1\" is still displayed after collapsing \"a\"")
+	}
+	l.ToggleSubListDisplayPath([]int{0})
+	if !l.items[0].SubList.display || l.items[0].SubList.items[0].SubList.display {
+		t.Error("expected \"a\" to be expanded and \"a1\" to be collapsed")
+	}
+	if count := l.GetVisibleItemCount(); count != 7 {
+		t.Errorf("got %d visible items, expected 7", count)
+	}
+	if got := strings.Join(expanded, ", "); got != "[0] false, [0] true" {
+		t.Errorf("got expanded events %q", got)
+	}
+
+	// Without the flag, the descendants keep their state.
+	l.SetCollapseResetsDescendants(false)
+	l.ToggleSubListDisplayPath([]int{0, 0})
+	l.ToggleSubListDisplayPath([]int{0})
+	l.ToggleSubListDisplayPath([]int{0})
+	if !l.items[0].SubList.items[0].SubList.display {
+		t.Error("sublist of \"a1\" was hidden without the flag")
+	}
+}
+
+func TestDeepListRecursiveCollapse(t *testing.T) {
+	l := NewDeepList().ShowSecondaryText(false)
+	l.addStreamItem(DeepListItemSpec{MainText: "alpha"}, 0)
+	l.addStreamItem(DeepListItemSpec{MainText: "beta", Expanded: true, Items: []DeepListItemSpec{
+		{MainText: "b1", Expanded: true, Items: []DeepListItemSpec{{MainText: "b1a"}}},
+	}}, 0)
+	l.SetCurrentItem([]int{1, 0, 0})
+	assertPath(t, "initial item", l.GetCurrentItem(), 1, 0, 0)
+
+	// The selection moves onto the nearest visible ancestor.
+	if !l.setTreeExpanded([]int{1}, l.items[1].SubList.items, false) {
+		t.Error("selection change was not reported")
+	}
+	assertPath(t, "current item", l.GetCurrentItem(), 1, 0)
+
+	// Alt with the expand recursive key collapses the whole subtree.
+	l.SetCurrentItem([]int{1})
+	pressKey(l, tcell.KeyRune, '*', tcell.ModNone)
+	if !l.items[1].SubList.display || !l.items[1].SubList.items[0].SubList.display {
+		t.Fatal("subtree was not expanded")
+	}
+	pressKey(l, tcell.KeyRune, '*', tcell.ModAlt)
+	if l.items[1].SubList.display || l.items[1].SubList.items[0].SubList.display {
+		t.Error("subtree was not collapsed")
+	}
+	assertPath(t, "current item after collapse", l.GetCurrentItem(), 1)
+
+	l.ExpandAll()
+	l.SetCurrentItem([]int{1, 0, 0})
+	l.CollapseAll()
+	assertPath(t, "current item after CollapseAll", l.GetCurrentItem(), 1)
+}
+
+func TestDeepListBorderPadding(t *testing.T) {
+	newList := func(rtl bool) *DeepList {
+		l := NewDeepList().
+			ShowSecondaryText(false).
+			SetIconColumn(true).
+			SetHighlightFullLine(true).
+			SetRTL(rtl).
+			SetSelectedStyle(tcell.StyleDefault.Background(tcell.ColorGreen))
+		l.SetBorder(true).SetBorderPadding(1, 1, 2, 2)
+		l.AddItem("alpha", "", 'a', nil).SetItemIcon([]int{0}, '*', tcell.StyleDefault)
+		l.AddItem("beta", "", 'b', nil)
+		return l
+	}
+	// assertPadding fails the test if anything was drawn into the padding
+	// of a list with the given width or if the selection highlight reached it.
+	assertPadding := func(name string, screen tcell.SimulationScreen, width int) {
+		t.Helper()
+		for y := 1; y < 5; y++ {
+			for _, x := range []int{1, 2, width - 3, width - 2} {
+				r, _, style, _ := screen.GetContent(x, y)
+				if _, bg, _ := style.Decompose(); r != ' ' || bg == tcell.ColorGreen {
+					t.Errorf("%s: got %q on %v in the padding at %d/%d", name, r, bg, x, y)
+				}
+			}
+		}
+		for _, x := range []int{0, width - 1} {
+			if r, _, _, _ := screen.GetContent(x, 3); r != Borders.Vertical {
+				t.Errorf("%s: got %q instead of the border at %d/3", name, r, x)
+			}
+		}
+	}
+
+	// The inner area starts at 3/2 and is 14 cells wide.
+	l := newList(false)
+	screen := drawDeepList(l, 20, 7)
+	assertPadding("left-to-right", screen, 20)
+	lines := deepListScreen(screen)
+	if row := string([]rune(lines[2])[3:14]); row != "(a) * alpha" {
+		t.Errorf("left-to-right: got first row %q, expected \"(a) * alpha\"", row)
+	}
+
+	l = newList(true)
+	screen = drawDeepList(l, 20, 7)
+	assertPadding("right-to-left", screen, 20)
+	lines = deepListScreen(screen)
+	if row := string([]rune(lines[2])[6:17]); row != "alpha * (a)" {
+		t.Errorf("right-to-left: got first row %q, expected \"alpha * (a)\"", row)
+	}
+
+	// Positions in the padding don't belong to any item.
+	clickDeepList(l, MouseLeftClick, 5, 3)
+	assertPath(t, "click on the second row", l.GetCurrentItem(), 1)
+	clickDeepList(l, MouseLeftClick, 10, 1)
+	assertPath(t, "click on the top padding", l.GetCurrentItem(), 1)
+
+	// In a narrow list, the columns shrink to the inner area.
+	for _, rtl := range []bool{false, true} {
+		screen := drawDeepList(newList(rtl), 8, 7)
+		assertPadding(fmt.Sprintf("narrow, right-to-left %t", rtl), screen, 8)
+	}
+}